@@ -0,0 +1,127 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrNotFound is returned when no cached value exists for a key
+var ErrNotFound = errors.New("cache entry not found")
+
+// Store is an on-disk cache of JSON-encoded values with a time-to-live
+type Store struct {
+	dir string
+	ttl time.Duration
+	now func() time.Time
+}
+
+// entry is the on-disk representation of a cached value
+type entry struct {
+	SavedAt time.Time       `json:"savedAt"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// NewStore creates a cache store rooted at dir whose entries are fresh for ttl
+func NewStore(dir string, ttl time.Duration) *Store {
+	return &Store{
+		dir: dir,
+		ttl: ttl,
+		now: time.Now,
+	}
+}
+
+// DefaultDir returns the default cache directory for speed-test
+func DefaultDir() (string, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate user cache directory: %w", err)
+	}
+	return filepath.Join(base, "speed-test-go"), nil
+}
+
+// Dir returns the directory the store writes to
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// TTL returns how long entries stay fresh
+func (s *Store) TTL() time.Duration {
+	return s.ttl
+}
+
+// Load decodes the cached value for key into v and returns its age.
+// Stale entries are still decoded; callers compare the age against TTL
+// or use Fresh to decide whether to trust them.
+func (s *Store) Load(key string, v any) (time.Duration, error) {
+	data, err := os.ReadFile(s.path(key))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, ErrNotFound
+		}
+		return 0, fmt.Errorf("failed to read cache entry: %w", err)
+	}
+
+	var e entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return 0, fmt.Errorf("failed to decode cache entry: %w", err)
+	}
+	if err := json.Unmarshal(e.Data, v); err != nil {
+		return 0, fmt.Errorf("failed to decode cached value: %w", err)
+	}
+
+	age := s.now().Sub(e.SavedAt)
+	if age < 0 {
+		age = 0
+	}
+	return age, nil
+}
+
+// Fresh reports whether an entry of the given age is within the TTL
+func (s *Store) Fresh(age time.Duration) bool {
+	return age < s.ttl
+}
+
+// Save encodes v and stores it under key
+func (s *Store) Save(key string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache value: %w", err)
+	}
+
+	encoded, err := json.Marshal(entry{SavedAt: s.now(), Data: data})
+	if err != nil {
+		return fmt.Errorf("failed to encode cache entry: %w", err)
+	}
+
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	// Write to a temporary file first so readers never see a partial entry
+	tmp, err := os.CreateTemp(s.dir, key+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create cache file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(encoded); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), s.path(key)); err != nil {
+		return fmt.Errorf("failed to store cache file: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) path(key string) string {
+	return filepath.Join(s.dir, key+".json")
+}
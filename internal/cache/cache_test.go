@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testValue struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func TestNewStore(t *testing.T) {
+	s := NewStore("/tmp/cache", time.Hour)
+
+	if s.Dir() != "/tmp/cache" {
+		t.Errorf("Expected dir /tmp/cache, got: %s", s.Dir())
+	}
+	if s.TTL() != time.Hour {
+		t.Errorf("Expected TTL 1h, got: %v", s.TTL())
+	}
+}
+
+func TestStore_SaveAndLoad(t *testing.T) {
+	s := NewStore(t.TempDir(), time.Hour)
+
+	if err := s.Save("servers", testValue{Name: "a", Count: 3}); err != nil {
+		t.Fatalf("Save() unexpected error: %v", err)
+	}
+
+	var got testValue
+	age, err := s.Load("servers", &got)
+	if err != nil {
+		t.Fatalf("Load() unexpected error: %v", err)
+	}
+
+	if got.Name != "a" || got.Count != 3 {
+		t.Errorf("Load() = %+v, want {a 3}", got)
+	}
+	if !s.Fresh(age) {
+		t.Errorf("Expected freshly saved entry to be fresh, age: %v", age)
+	}
+}
+
+func TestStore_LoadMissing(t *testing.T) {
+	s := NewStore(t.TempDir(), time.Hour)
+
+	var got testValue
+	_, err := s.Load("missing", &got)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got: %v", err)
+	}
+}
+
+func TestStore_LoadCorrupt(t *testing.T) {
+	dir := t.TempDir()
+	s := NewStore(dir, time.Hour)
+
+	if err := os.WriteFile(filepath.Join(dir, "bad.json"), []byte("not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var got testValue
+	_, err := s.Load("bad", &got)
+	if err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected decode error, got: %v", err)
+	}
+}
+
+func TestStore_Age(t *testing.T) {
+	s := NewStore(t.TempDir(), time.Minute)
+
+	now := time.Now()
+	s.now = func() time.Time { return now }
+	if err := s.Save("location", testValue{Name: "b"}); err != nil {
+		t.Fatalf("Save() unexpected error: %v", err)
+	}
+
+	s.now = func() time.Time { return now.Add(2 * time.Minute) }
+
+	var got testValue
+	age, err := s.Load("location", &got)
+	if err != nil {
+		t.Fatalf("Load() unexpected error: %v", err)
+	}
+
+	if age != 2*time.Minute {
+		t.Errorf("Expected age 2m, got: %v", age)
+	}
+	if s.Fresh(age) {
+		t.Error("Expected entry older than TTL to be stale")
+	}
+}
+
+func TestDefaultDir(t *testing.T) {
+	dir, err := DefaultDir()
+	if err != nil {
+		t.Skipf("No user cache directory: %v", err)
+	}
+
+	if filepath.Base(dir) != "speed-test-go" {
+		t.Errorf("Expected dir to end in speed-test-go, got: %s", dir)
+	}
+}
@@ -7,25 +7,44 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/user/speed-test-go/internal/network"
 	"github.com/user/speed-test-go/pkg/types"
 )
 
 const configURL = "http://speedtest.net/speedtest-config.php"
 
-// DetectUserLocation detects the user's location based on their IP
-func DetectUserLocation(ctx context.Context) (*types.UserLocation, error) {
+// LocateTimeout bounds each request made by a location provider
+const LocateTimeout = 15 * time.Second
+
+// SpeedtestProvider detects the user's location from the speedtest.net config XML
+type SpeedtestProvider struct {
+	URL    string
+	Client *http.Client // nil uses the default network factory
+}
+
+// NewSpeedtestProvider creates a provider using the speedtest.net config endpoint
+func NewSpeedtestProvider() *SpeedtestProvider {
+	return &SpeedtestProvider{URL: configURL}
+}
+
+// Name returns the provider name
+func (p *SpeedtestProvider) Name() string {
+	return "speedtest"
+}
+
+// Locate fetches the speedtest.net config and returns the client location
+func (p *SpeedtestProvider) Locate(ctx context.Context) (*types.UserLocation, error) {
 	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
-	req, err := http.NewRequestWithContext(ctx, "GET", configURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", p.URL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
-
-	client := &http.Client{
-		Timeout: 15 * time.Second,
+	client := p.Client
+	if client == nil {
+		client = network.DefaultFactory().Client(LocateTimeout)
 	}
 
 	resp, err := client.Do(req)
@@ -51,3 +70,8 @@ func DetectUserLocation(ctx context.Context) (*types.UserLocation, error) {
 		ISP:       settings.Client.ISP,
 	}, nil
 }
+
+// DetectUserLocation detects the user's location based on their IP
+func DetectUserLocation(ctx context.Context) (*types.UserLocation, error) {
+	return NewSpeedtestProvider().Locate(ctx)
+}
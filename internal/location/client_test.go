@@ -1,8 +1,11 @@
 package location
 
 import (
+	"context"
 	"encoding/xml"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/user/speed-test-go/pkg/types"
@@ -209,3 +212,40 @@ func TestDetectUserLocation_ValidXMLWithDifferentValues(t *testing.T) {
 		})
 	}
 }
+
+func TestSpeedtestProvider_Locate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/xml")
+		w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<settings>
+  <client ip="203.0.113.9" lat="-6.2088" lon="106.8456" isp="Example ISP" country="ID" />
+</settings>`))
+	}))
+	defer server.Close()
+
+	p := &SpeedtestProvider{URL: server.URL}
+
+	if p.Name() != "speedtest" {
+		t.Errorf("Expected name 'speedtest', got: %s", p.Name())
+	}
+
+	loc, err := p.Locate(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if loc.IP != "203.0.113.9" || loc.Latitude != -6.2088 || loc.Longitude != 106.8456 || loc.ISP != "Example ISP" {
+		t.Errorf("Unexpected location: %+v", loc)
+	}
+}
+
+func TestSpeedtestProvider_HTTPError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	p := &SpeedtestProvider{URL: server.URL}
+	if _, err := p.Locate(context.Background()); err == nil {
+		t.Error("Expected error for non-200 status")
+	}
+}
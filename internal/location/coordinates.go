@@ -0,0 +1,33 @@
+package location
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseCoordinates parses a "lat,lon" string into latitude and longitude
+func ParseCoordinates(s string) (float64, float64, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid location %q: expected lat,lon", s)
+	}
+
+	lat, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid latitude %q: %w", parts[0], err)
+	}
+	lon, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid longitude %q: %w", parts[1], err)
+	}
+
+	if lat < -90 || lat > 90 {
+		return 0, 0, fmt.Errorf("latitude %v out of range [-90, 90]", lat)
+	}
+	if lon < -180 || lon > 180 {
+		return 0, 0, fmt.Errorf("longitude %v out of range [-180, 180]", lon)
+	}
+
+	return lat, lon, nil
+}
@@ -0,0 +1,40 @@
+package location
+
+import "testing"
+
+func TestParseCoordinates(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		lat     float64
+		lon     float64
+		wantErr bool
+	}{
+		{name: "valid", input: "-6.2088,106.8456", lat: -6.2088, lon: 106.8456},
+		{name: "with spaces", input: " 52.52 , 13.405 ", lat: 52.52, lon: 13.405},
+		{name: "integers", input: "0,0", lat: 0, lon: 0},
+		{name: "missing lon", input: "52.52", wantErr: true},
+		{name: "too many parts", input: "1,2,3", wantErr: true},
+		{name: "not a number", input: "north,east", wantErr: true},
+		{name: "lat out of range", input: "91,0", wantErr: true},
+		{name: "lon out of range", input: "0,-181", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lat, lon, err := ParseCoordinates(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseCoordinates(%q) expected error", tt.input)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseCoordinates(%q) unexpected error: %v", tt.input, err)
+			}
+			if lat != tt.lat || lon != tt.lon {
+				t.Errorf("ParseCoordinates(%q) = %v, %v, want %v, %v", tt.input, lat, lon, tt.lat, tt.lon)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package location
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/user/speed-test-go/internal/network"
+	"github.com/user/speed-test-go/pkg/types"
+)
+
+// FieldMapping names the JSON fields holding each part of the location.
+// Nested fields are addressed with dots, e.g. "location.lat".
+type FieldMapping struct {
+	IP        string
+	Latitude  string
+	Longitude string
+	ISP       string
+}
+
+// DefaultFieldMapping matches the response shape of ipapi.co-style services
+var DefaultFieldMapping = FieldMapping{
+	IP:        "ip",
+	Latitude:  "latitude",
+	Longitude: "longitude",
+	ISP:       "org",
+}
+
+// ParseFieldMapping parses a mapping such as "lat=location.lat,lon=location.lng".
+// Keys are ip, lat, lon and isp; unspecified keys keep their default field.
+func ParseFieldMapping(s string) (FieldMapping, error) {
+	mapping := DefaultFieldMapping
+	if strings.TrimSpace(s) == "" {
+		return mapping, nil
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		key, field, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		field = strings.TrimSpace(field)
+		if !ok || field == "" {
+			return FieldMapping{}, fmt.Errorf("invalid field mapping %q: expected key=field", pair)
+		}
+
+		switch key {
+		case "ip":
+			mapping.IP = field
+		case "lat", "latitude":
+			mapping.Latitude = field
+		case "lon", "longitude":
+			mapping.Longitude = field
+		case "isp":
+			mapping.ISP = field
+		default:
+			return FieldMapping{}, fmt.Errorf("unknown field mapping key %q", key)
+		}
+	}
+
+	return mapping, nil
+}
+
+// JSONProvider detects the user's location with a JSON IP-geolocation API
+type JSONProvider struct {
+	URL    string
+	Fields FieldMapping
+	Client *http.Client // nil uses the default network factory
+}
+
+// NewJSONProvider creates a provider querying url with the given field mapping
+func NewJSONProvider(url string, fields FieldMapping) *JSONProvider {
+	return &JSONProvider{
+		URL:    url,
+		Fields: fields,
+	}
+}
+
+// Name returns the provider name
+func (p *JSONProvider) Name() string {
+	return "json"
+}
+
+// Locate queries the geolocation API and maps the response into a location
+func (p *JSONProvider) Locate(ctx context.Context) (*types.UserLocation, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", p.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	client := p.Client
+	if client == nil {
+		client = network.DefaultFactory().Client(LocateTimeout)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch location: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned status %d", resp.StatusCode)
+	}
+
+	var body map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode location: %w", err)
+	}
+
+	lat, err := lookupFloat(body, p.Fields.Latitude)
+	if err != nil {
+		return nil, err
+	}
+	lon, err := lookupFloat(body, p.Fields.Longitude)
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.UserLocation{
+		IP:        lookupString(body, p.Fields.IP),
+		Latitude:  lat,
+		Longitude: lon,
+		ISP:       lookupString(body, p.Fields.ISP),
+	}, nil
+}
+
+// lookup resolves a dotted field path in a decoded JSON object
+func lookup(body map[string]any, path string) (any, bool) {
+	if path == "" {
+		return nil, false
+	}
+
+	var current any = body
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		current, ok = obj[key]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+func lookupString(body map[string]any, path string) string {
+	v, ok := lookup(body, path)
+	if !ok || v == nil {
+		return ""
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprint(v)
+}
+
+// lookupFloat resolves a coordinate that may be encoded as a number or string
+func lookupFloat(body map[string]any, path string) (float64, error) {
+	v, ok := lookup(body, path)
+	if !ok {
+		return 0, fmt.Errorf("field %q missing from response", path)
+	}
+
+	switch val := v.(type) {
+	case float64:
+		return val, nil
+	case string:
+		f, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return 0, fmt.Errorf("field %q is not a number: %w", path, err)
+		}
+		return f, nil
+	default:
+		return 0, fmt.Errorf("field %q is not a number", path)
+	}
+}
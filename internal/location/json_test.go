@@ -0,0 +1,97 @@
+package location
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseFieldMapping(t *testing.T) {
+	t.Run("empty uses defaults", func(t *testing.T) {
+		m, err := ParseFieldMapping("")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if m != DefaultFieldMapping {
+			t.Errorf("Expected default mapping, got: %+v", m)
+		}
+	})
+
+	t.Run("overrides", func(t *testing.T) {
+		m, err := ParseFieldMapping("ip=query, lat=lat,lon=lon,isp=isp")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		want := FieldMapping{IP: "query", Latitude: "lat", Longitude: "lon", ISP: "isp"}
+		if m != want {
+			t.Errorf("Expected %+v, got: %+v", want, m)
+		}
+	})
+
+	t.Run("partial override", func(t *testing.T) {
+		m, err := ParseFieldMapping("lat=loc.latitude")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if m.Latitude != "loc.latitude" || m.Longitude != DefaultFieldMapping.Longitude {
+			t.Errorf("Unexpected mapping: %+v", m)
+		}
+	})
+
+	for _, bad := range []string{"lat", "lat=", "country=cc"} {
+		if _, err := ParseFieldMapping(bad); err == nil {
+			t.Errorf("Expected error for %q", bad)
+		}
+	}
+}
+
+func TestJSONProvider_Locate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"query": "203.0.113.7", "isp": "Example ISP", "loc": {"lat": "-6.2088", "lng": 106.8456}}`))
+	}))
+	defer server.Close()
+
+	p := NewJSONProvider(server.URL, FieldMapping{IP: "query", Latitude: "loc.lat", Longitude: "loc.lng", ISP: "isp"})
+
+	if p.Name() != "json" {
+		t.Errorf("Expected name 'json', got: %s", p.Name())
+	}
+
+	loc, err := p.Locate(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if loc.IP != "203.0.113.7" || loc.ISP != "Example ISP" {
+		t.Errorf("Unexpected IP/ISP: %+v", loc)
+	}
+	if loc.Latitude != -6.2088 || loc.Longitude != 106.8456 {
+		t.Errorf("Unexpected coordinates: %v,%v", loc.Latitude, loc.Longitude)
+	}
+}
+
+func TestJSONProvider_MissingField(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ip": "203.0.113.7"}`))
+	}))
+	defer server.Close()
+
+	p := NewJSONProvider(server.URL, DefaultFieldMapping)
+	if _, err := p.Locate(context.Background()); err == nil {
+		t.Error("Expected error when coordinates are missing")
+	}
+}
+
+func TestJSONProvider_HTTPError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	p := NewJSONProvider(server.URL, DefaultFieldMapping)
+	if _, err := p.Locate(context.Background()); err == nil {
+		t.Error("Expected error for non-200 status")
+	}
+}
@@ -0,0 +1,73 @@
+package location
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/user/speed-test-go/pkg/types"
+)
+
+// Provider detects the user's location
+type Provider interface {
+	// Name returns a short identifier used in errors and flags
+	Name() string
+	// Locate returns the user's location
+	Locate(ctx context.Context) (*types.UserLocation, error)
+}
+
+// ManualProvider returns fixed coordinates without any network access
+type ManualProvider struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// Name returns the provider name
+func (p *ManualProvider) Name() string {
+	return "manual"
+}
+
+// Locate returns the configured coordinates
+func (p *ManualProvider) Locate(ctx context.Context) (*types.UserLocation, error) {
+	return &types.UserLocation{
+		Latitude:  p.Latitude,
+		Longitude: p.Longitude,
+	}, nil
+}
+
+// Chain tries each provider in order and returns the first successful location
+type Chain []Provider
+
+// Name returns the provider names joined in fallback order
+func (c Chain) Name() string {
+	names := make([]string, 0, len(c))
+	for _, p := range c {
+		names = append(names, p.Name())
+	}
+	return strings.Join(names, ",")
+}
+
+// Locate returns the location from the first provider that succeeds.
+// If every provider fails, the returned error includes each failure.
+func (c Chain) Locate(ctx context.Context) (*types.UserLocation, error) {
+	if len(c) == 0 {
+		return nil, fmt.Errorf("no location providers configured")
+	}
+
+	var errs []error
+	for _, p := range c {
+		loc, err := p.Locate(ctx)
+		if err == nil {
+			return loc, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+
+		// Stop early rather than report every provider as timed out
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	return nil, errors.Join(errs...)
+}
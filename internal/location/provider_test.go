@@ -0,0 +1,91 @@
+package location
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/user/speed-test-go/pkg/types"
+)
+
+type stubProvider struct {
+	name  string
+	loc   *types.UserLocation
+	err   error
+	calls int
+}
+
+func (p *stubProvider) Name() string {
+	return p.name
+}
+
+func (p *stubProvider) Locate(ctx context.Context) (*types.UserLocation, error) {
+	p.calls++
+	return p.loc, p.err
+}
+
+func TestManualProvider(t *testing.T) {
+	p := &ManualProvider{Latitude: -6.2, Longitude: 106.8}
+
+	if p.Name() != "manual" {
+		t.Errorf("Expected name 'manual', got: %s", p.Name())
+	}
+
+	loc, err := p.Locate(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if loc.Latitude != -6.2 || loc.Longitude != 106.8 {
+		t.Errorf("Expected -6.2,106.8, got: %v,%v", loc.Latitude, loc.Longitude)
+	}
+}
+
+func TestChain_FirstSuccessWins(t *testing.T) {
+	failing := &stubProvider{name: "a", err: errors.New("down")}
+	working := &stubProvider{name: "b", loc: &types.UserLocation{IP: "203.0.113.1"}}
+	unused := &stubProvider{name: "c", loc: &types.UserLocation{IP: "198.51.100.1"}}
+
+	chain := Chain{failing, working, unused}
+
+	loc, err := chain.Locate(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if loc.IP != "203.0.113.1" {
+		t.Errorf("Expected location from second provider, got: %s", loc.IP)
+	}
+	if unused.calls != 0 {
+		t.Error("Expected providers after the first success not to be called")
+	}
+}
+
+func TestChain_AllFail(t *testing.T) {
+	chain := Chain{
+		&stubProvider{name: "a", err: errors.New("timeout")},
+		&stubProvider{name: "b", err: errors.New("status 503")},
+	}
+
+	_, err := chain.Locate(context.Background())
+	if err == nil {
+		t.Fatal("Expected error when all providers fail")
+	}
+	for _, want := range []string{"a: timeout", "b: status 503"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to contain %q, got: %v", want, err)
+		}
+	}
+}
+
+func TestChain_Empty(t *testing.T) {
+	if _, err := (Chain{}).Locate(context.Background()); err == nil {
+		t.Error("Expected error for empty chain")
+	}
+}
+
+func TestChain_Name(t *testing.T) {
+	chain := Chain{NewSpeedtestProvider(), &ManualProvider{}}
+	if chain.Name() != "speedtest,manual" {
+		t.Errorf("Expected 'speedtest,manual', got: %s", chain.Name())
+	}
+}
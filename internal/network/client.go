@@ -1,31 +1,37 @@
 package network
 
 import (
+	"context"
 	"crypto/tls"
 	"net"
 	"net/http"
+	"net/url"
 	"time"
 )
 
+const (
+	dialTimeout = 10 * time.Second
+	keepAlive   = 30 * time.Second
+
+	// DefaultTimeout is the overall timeout for general purpose clients
+	DefaultTimeout = 60 * time.Second
+
+	// DefaultUserAgent is sent with every request made by a Factory client
+	DefaultUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+)
+
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
 // NewHTTPClient creates an HTTP client optimized for speed testing
+// using default options and the proxy from the environment
 func NewHTTPClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   dialTimeout,
+		KeepAlive: keepAlive,
+	}
 	return &http.Client{
-		Transport: &http.Transport{
-			DialContext: (&net.Dialer{
-				Timeout:   10 * time.Second,
-				KeepAlive: 30 * time.Second,
-			}).DialContext,
-			TLSHandshakeTimeout:   10 * time.Second,
-			ResponseHeaderTimeout: 30 * time.Second,
-			ExpectContinueTimeout: 1 * time.Second,
-			MaxIdleConns:          10,
-			IdleConnTimeout:       90 * time.Second,
-			TLSClientConfig: &tls.Config{
-				MinVersion:         tls.VersionTLS12,
-				InsecureSkipVerify: false,
-			},
-		},
-		Timeout: 60 * time.Second,
+		Transport: newTransport(dialer.DialContext, nil, nil),
+		Timeout:   DefaultTimeout,
 	}
 }
 
@@ -41,3 +47,25 @@ func DownloadClient() *http.Client {
 func UploadClient() *http.Client {
 	return NewHTTPClient()
 }
+
+// newTransport creates the transport settings shared by every client.
+// A nil tlsConfig verifies servers against the system roots.
+func newTransport(dial dialFunc, proxy *url.URL, tlsConfig *tls.Config) *http.Transport {
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{
+			MinVersion:         tls.VersionTLS12,
+			InsecureSkipVerify: false,
+		}
+	}
+
+	return &http.Transport{
+		Proxy:                 proxyFunc(proxy),
+		DialContext:           dial,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       90 * time.Second,
+		TLSClientConfig:       tlsConfig,
+	}
+}
@@ -0,0 +1,107 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/user/speed-test-go/pkg/types"
+)
+
+const dnsPort = "53"
+
+// parseDNSServer parses a resolver address such as "1.1.1.1", "tcp://9.9.9.9"
+// or "udp://[2606:4700::1111]:53" into a network and host:port address.
+// Queries use UDP unless the tcp scheme is given.
+func parseDNSServer(raw string) (string, string, error) {
+	if raw == "" {
+		return "", "", nil
+	}
+
+	network, addr := "udp", raw
+	if scheme, rest, ok := strings.Cut(raw, "://"); ok {
+		switch scheme {
+		case "udp", "tcp":
+			network, addr = scheme, rest
+		default:
+			return "", "", fmt.Errorf("unsupported DNS server scheme %q (use udp or tcp)", scheme)
+		}
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		// No port given; a bare IPv6 address may still be bracketed
+		host, port = strings.Trim(addr, "[]"), dnsPort
+	}
+	if net.ParseIP(host) == nil {
+		return "", "", fmt.Errorf("invalid DNS server %q: expected an IP address", raw)
+	}
+
+	return network, net.JoinHostPort(host, port), nil
+}
+
+// newResolver returns a resolver that sends every query to addr over network.
+// Queries are sent from local if set, so they leave through the same
+// interface as the test connections.
+func newResolver(network, addr string, local net.IP) *net.Resolver {
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	if local != nil {
+		if network == "tcp" {
+			dialer.LocalAddr = &net.TCPAddr{IP: local}
+		} else {
+			dialer.LocalAddr = &net.UDPAddr{IP: local}
+		}
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, addr)
+		},
+	}
+}
+
+// Resolver returns the resolver used for connections made by the factory
+func (f *Factory) Resolver() *net.Resolver {
+	if f.resolver != nil {
+		return f.resolver
+	}
+	return net.DefaultResolver
+}
+
+// ResolveHost measures how long it takes to resolve the host part of host.
+// IP addresses are returned without a lookup and with a zero duration.
+func (f *Factory) ResolveHost(ctx context.Context, host string) (*types.DNSResult, error) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	result := &types.DNSResult{
+		Host:   host,
+		Server: f.dnsAddr,
+	}
+
+	if net.ParseIP(host) != nil {
+		result.Addresses = []string{host}
+		return result, nil
+	}
+
+	start := time.Now()
+	addrs, err := f.Resolver().LookupIPAddr(ctx, host)
+	result.Duration = float64(time.Since(start).Microseconds()) / 1000
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", host, err)
+	}
+
+	for _, addr := range addrs {
+		if f.opts.Family.Matches(addr.IP) {
+			result.Addresses = append(result.Addresses, addr.IP.String())
+		}
+	}
+	if len(result.Addresses) == 0 {
+		return nil, fmt.Errorf("no %s address found for %s", f.opts.Family.Network("ip"), host)
+	}
+
+	return result, nil
+}
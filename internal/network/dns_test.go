@@ -0,0 +1,173 @@
+package network
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"sync/atomic"
+	"testing"
+)
+
+// dnsServer is a minimal UDP DNS server answering every A query with 127.0.0.1
+type dnsServer struct {
+	addr    string
+	queries atomic.Int32
+	client  atomic.Value // address the last query came from
+}
+
+// startDNSServer runs a dnsServer until the test ends
+func startDNSServer(t *testing.T) *dnsServer {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	srv := &dnsServer{addr: conn.LocalAddr().String()}
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			srv.queries.Add(1)
+			srv.client.Store(addr.String())
+			if reply := dnsReply(buf[:n]); reply != nil {
+				conn.WriteTo(reply, addr)
+			}
+		}
+	}()
+
+	return srv
+}
+
+// dnsReply builds the answer to a single-question query
+func dnsReply(query []byte) []byte {
+	if len(query) < 12 {
+		return nil
+	}
+
+	// Skip the question name to find its type
+	end := 12
+	for end < len(query) && query[end] != 0 {
+		end += int(query[end]) + 1
+	}
+	end += 5 // terminating label, type and class
+	if end > len(query) {
+		return nil
+	}
+	qtype := binary.BigEndian.Uint16(query[end-4:])
+
+	reply := append([]byte{}, query[:end]...)
+	binary.BigEndian.PutUint16(reply[2:], 0x8180) // response, recursion available
+	binary.BigEndian.PutUint16(reply[8:], 0)      // no authority records
+	binary.BigEndian.PutUint16(reply[10:], 0)     // no additional records
+
+	if qtype != 1 {
+		binary.BigEndian.PutUint16(reply[6:], 0)
+		return reply
+	}
+
+	binary.BigEndian.PutUint16(reply[6:], 1)
+	return append(reply,
+		0xc0, 0x0c, // pointer to the question name
+		0x00, 0x01, // type A
+		0x00, 0x01, // class IN
+		0x00, 0x00, 0x00, 0x3c, // TTL
+		0x00, 0x04, // data length
+		127, 0, 0, 1,
+	)
+}
+
+func TestParseDNSServer(t *testing.T) {
+	tests := []struct {
+		raw     string
+		network string
+		addr    string
+	}{
+		{"1.1.1.1", "udp", "1.1.1.1:53"},
+		{"1.1.1.1:5353", "udp", "1.1.1.1:5353"},
+		{"tcp://9.9.9.9", "tcp", "9.9.9.9:53"},
+		{"udp://[2606:4700::1111]:53", "udp", "[2606:4700::1111]:53"},
+		{"2606:4700::1111", "udp", "[2606:4700::1111]:53"},
+		{"", "", ""},
+	}
+
+	for _, tt := range tests {
+		network, addr, err := parseDNSServer(tt.raw)
+		if err != nil {
+			t.Errorf("parseDNSServer(%q) unexpected error: %v", tt.raw, err)
+			continue
+		}
+		if network != tt.network || addr != tt.addr {
+			t.Errorf("parseDNSServer(%q) = %s, %s, want %s, %s", tt.raw, network, addr, tt.network, tt.addr)
+		}
+	}
+
+	for _, raw := range []string{"dns.google", "https://1.1.1.1", "tcp://"} {
+		if _, _, err := parseDNSServer(raw); err == nil {
+			t.Errorf("parseDNSServer(%q) expected error", raw)
+		}
+	}
+}
+
+func TestFactory_ResolveHost_IP(t *testing.T) {
+	result, err := DefaultFactory().ResolveHost(context.Background(), "127.0.0.1:8080")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Host != "127.0.0.1" || result.Duration != 0 {
+		t.Errorf("Expected IP address to be returned without a lookup, got: %+v", result)
+	}
+}
+
+func TestFactory_ResolveHost_DNSServer(t *testing.T) {
+	srv := startDNSServer(t)
+	addr := srv.addr
+
+	factory, err := NewFactory(Options{DNSServer: addr})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	result, err := factory.ResolveHost(context.Background(), "speedtest.example.test:8080")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if srv.queries.Load() == 0 {
+		t.Error("Expected lookup to be sent to the configured DNS server")
+	}
+	if result.Server != addr {
+		t.Errorf("Expected server %s, got: %s", addr, result.Server)
+	}
+	if result.Host != "speedtest.example.test" {
+		t.Errorf("Expected host without port, got: %s", result.Host)
+	}
+	if len(result.Addresses) != 1 || result.Addresses[0] != "127.0.0.1" {
+		t.Errorf("Expected address 127.0.0.1, got: %v", result.Addresses)
+	}
+	if result.Duration <= 0 {
+		t.Errorf("Expected positive lookup duration, got: %f", result.Duration)
+	}
+}
+
+func TestFactory_ResolveHost_DNSServerBound(t *testing.T) {
+	srv := startDNSServer(t)
+
+	factory, err := NewFactory(Options{DNSServer: srv.addr, SourceAddr: "127.0.0.2"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := factory.ResolveHost(context.Background(), "speedtest.example.test"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	client, _ := srv.client.Load().(string)
+	if host, _, _ := net.SplitHostPort(client); host != "127.0.0.2" {
+		t.Errorf("Expected queries from the source address 127.0.0.2, got: %q", client)
+	}
+}
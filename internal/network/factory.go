@@ -0,0 +1,233 @@
+package network
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Factory builds the HTTP clients used by every test phase. Clients from one
+// factory share a transport, so connection binding, address family, proxy,
+// DNS resolver, TLS settings, User-Agent and tracing are applied uniformly.
+type Factory struct {
+	opts      Options
+	localAddr net.IP
+	proxy     *url.URL
+	resolver  *net.Resolver
+	dnsAddr   string
+	userAgent string
+	trace     *httptrace.ClientTrace
+	base      http.RoundTripper
+	transfer  http.RoundTripper // download and upload transport, nil to use base
+	tlsConfig *tls.Config
+	conns     *localAddrs
+}
+
+var (
+	defaultFactory     *Factory
+	defaultFactoryOnce sync.Once
+)
+
+// NewFactory validates opts and creates a factory applying them
+func NewFactory(opts Options) (*Factory, error) {
+	ip, err := resolveLocalAddr(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	proxy, err := parseProxy(opts.Proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	dnsNetwork, dnsAddr, err := parseDNSServer(opts.DNSServer)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := newTLSConfig(opts.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &Factory{
+		opts:      opts,
+		localAddr: ip,
+		proxy:     proxy,
+		dnsAddr:   dnsAddr,
+		userAgent: DefaultUserAgent,
+		tlsConfig: tlsConfig,
+		conns:     newLocalAddrs(),
+	}
+	if dnsAddr != "" {
+		f.resolver = newResolver(dnsNetwork, dnsAddr, ip)
+	}
+	f.base = newTransport(f.dialContext(), proxy, tlsConfig)
+	// Only transfers use HTTP/2; discovery, location and latency requests
+	// keep HTTP/1.1, which every server speaks
+	if opts.HTTP2 {
+		transfer := newTransport(f.dialContext(), proxy, tlsConfig)
+		enableHTTP2(transfer)
+		f.transfer = transfer
+	}
+	return f, nil
+}
+
+// NewFactoryWithTransport creates a factory whose clients send every request
+// through rt. It is mainly useful for substituting a RoundTripper in tests.
+func NewFactoryWithTransport(rt http.RoundTripper) *Factory {
+	return &Factory{
+		userAgent: DefaultUserAgent,
+		base:      rt,
+	}
+}
+
+// DefaultFactory returns a shared factory with default options
+func DefaultFactory() *Factory {
+	defaultFactoryOnce.Do(func() {
+		// Default options always validate
+		defaultFactory, _ = NewFactory(Options{})
+	})
+	return defaultFactory
+}
+
+// WithFamily returns a factory with the same settings restricted to family
+func (f *Factory) WithFamily(family Family) (*Factory, error) {
+	opts := f.opts
+	opts.Family = family
+
+	derived, err := NewFactory(opts)
+	if err != nil {
+		return nil, err
+	}
+	derived.userAgent = f.userAgent
+	derived.trace = f.trace
+	return derived, nil
+}
+
+// SetUserAgent sets the User-Agent sent with every request
+func (f *Factory) SetUserAgent(userAgent string) {
+	f.userAgent = userAgent
+}
+
+// SetTrace attaches trace hooks to every request
+func (f *Factory) SetTrace(trace *httptrace.ClientTrace) {
+	f.trace = trace
+}
+
+// Options returns the options the factory was created with
+func (f *Factory) Options() Options {
+	return f.opts
+}
+
+// Insecure reports whether TLS certificate verification is disabled
+func (f *Factory) Insecure() bool {
+	return f.opts.TLS.Insecure
+}
+
+// LocalAddr returns the local IP connections are bound to, or nil if unbound
+func (f *Factory) LocalAddr() net.IP {
+	return f.localAddr
+}
+
+// Transport returns the shared round tripper used by the factory's clients
+// other than the download and upload clients
+func (f *Factory) Transport() http.RoundTripper {
+	return &factoryTransport{factory: f, base: f.base}
+}
+
+// Client creates a client with the given overall timeout (0 for none)
+func (f *Factory) Client(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Transport: f.Transport(),
+		Timeout:   timeout,
+	}
+}
+
+// transferClient creates a client for download or upload requests, which
+// use HTTP/2 when enabled
+func (f *Factory) transferClient(timeout time.Duration) *http.Client {
+	base := f.transfer
+	if base == nil {
+		base = f.base
+	}
+	return &http.Client{
+		Transport: &factoryTransport{factory: f, base: base},
+		Timeout:   timeout,
+	}
+}
+
+// HTTPClient creates a general purpose client with the default timeout
+func (f *Factory) HTTPClient() *http.Client {
+	return f.Client(DefaultTimeout)
+}
+
+// DownloadClient creates a client without an overall timeout for large downloads
+func (f *Factory) DownloadClient() *http.Client {
+	return f.transferClient(0)
+}
+
+// UploadClient creates a client for uploads
+func (f *Factory) UploadClient() *http.Client {
+	return f.transferClient(DefaultTimeout)
+}
+
+// DialContext opens a raw connection with the factory's binding, address
+// family and resolver. It is tunnelled through the explicit proxy unless addr
+// is listed in NO_PROXY; proxies from the environment apply only to HTTP
+// clients.
+func (f *Factory) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if f.proxy == nil || bypassProxy(addr, noProxyList()) {
+		return f.dialContext()(ctx, network, addr)
+	}
+	return dialProxy(ctx, f.dialContext(), f.proxy, f.tlsConfig, addr)
+}
+
+// dialContext dials from the bound local address, restricted to the configured
+// family, and records the local address of each connection
+func (f *Factory) dialContext() dialFunc {
+	dialer := &net.Dialer{
+		Timeout:   dialTimeout,
+		KeepAlive: keepAlive,
+		Resolver:  f.resolver,
+	}
+	if f.localAddr != nil {
+		dialer.LocalAddr = &net.TCPAddr{IP: f.localAddr}
+	}
+
+	family := f.opts.Family
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, family.Network(network), addr)
+		if err != nil {
+			return nil, err
+		}
+		f.conns.record(addr, conn)
+		return conn, nil
+	}
+}
+
+// factoryTransport applies per-request factory settings before delegating
+// to one of the factory's transports
+type factoryTransport struct {
+	factory *Factory
+	base    http.RoundTripper
+}
+
+// RoundTrip sets the User-Agent and trace hooks, then sends the request
+func (t *factoryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if t.factory.trace != nil {
+		ctx = httptrace.WithClientTrace(ctx, t.factory.trace)
+	}
+
+	// RoundTrippers must not modify the caller's request
+	req = req.Clone(ctx)
+	req.Header.Set("User-Agent", t.factory.userAgent)
+
+	return t.base.RoundTrip(req)
+}
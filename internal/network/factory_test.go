@@ -0,0 +1,127 @@
+package network
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"strings"
+	"testing"
+)
+
+// roundTripFunc adapts a function to http.RoundTripper
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestFactory_UserAgent(t *testing.T) {
+	var gotUA string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUA = r.Header.Get("User-Agent")
+	}))
+	defer server.Close()
+
+	factory, err := NewFactory(Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	resp, err := factory.HTTPClient().Get(server.URL)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if gotUA != DefaultUserAgent {
+		t.Errorf("Expected default User-Agent, got: %q", gotUA)
+	}
+
+	factory.SetUserAgent("speed-test-go/test")
+	resp, err = factory.DownloadClient().Get(server.URL)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if gotUA != "speed-test-go/test" {
+		t.Errorf("Expected custom User-Agent, got: %q", gotUA)
+	}
+}
+
+func TestFactory_WithTransport(t *testing.T) {
+	var requests []string
+	factory := NewFactoryWithTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		requests = append(requests, req.URL.Path)
+		if req.Header.Get("User-Agent") == "" {
+			t.Error("Expected User-Agent to be set before the transport")
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader("stub")),
+			Request:    req,
+		}, nil
+	}))
+
+	for _, client := range []*http.Client{factory.HTTPClient(), factory.DownloadClient(), factory.UploadClient()} {
+		resp, err := client.Get("http://speedtest.example.invalid/speedtest/latency.txt")
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	if len(requests) != 3 {
+		t.Errorf("Expected every client to use the stub transport, got %d requests", len(requests))
+	}
+}
+
+func TestFactory_Trace(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	factory, err := NewFactory(Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	connected := false
+	factory.SetTrace(&httptrace.ClientTrace{
+		GotConn: func(httptrace.GotConnInfo) { connected = true },
+	})
+
+	resp, err := factory.HTTPClient().Get(server.URL)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if !connected {
+		t.Error("Expected trace hooks to run")
+	}
+}
+
+func TestFactory_WithFamily(t *testing.T) {
+	factory, err := NewFactory(Options{SourceAddr: "127.0.0.1"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	factory.SetUserAgent("speed-test-go/test")
+
+	v4, err := factory.WithFamily(FamilyIPv4)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if v4.Options().Family != FamilyIPv4 || v4.Options().SourceAddr != "127.0.0.1" {
+		t.Errorf("Expected derived options to keep the source address, got: %+v", v4.Options())
+	}
+	if v4.userAgent != "speed-test-go/test" {
+		t.Errorf("Expected derived factory to keep the User-Agent, got: %q", v4.userAgent)
+	}
+	if factory.Options().Family != FamilyAny {
+		t.Error("Expected original factory to be unchanged")
+	}
+
+	if _, err := factory.WithFamily(FamilyIPv6); err == nil {
+		t.Error("Expected error for IPv4 source address with IPv6 family")
+	}
+}
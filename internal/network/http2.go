@@ -0,0 +1,15 @@
+package network
+
+import "net/http"
+
+// enableHTTP2 switches a transfer transport to HTTP/2 only: TLS connections
+// negotiate h2 and plain-text connections use h2c with prior knowledge.
+// Requests to one host share a single connection as concurrent streams.
+func enableHTTP2(t *http.Transport) {
+	protocols := new(http.Protocols)
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(true)
+
+	t.Protocols = protocols
+	t.MaxConnsPerHost = 1
+}
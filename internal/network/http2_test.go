@@ -0,0 +1,109 @@
+package network
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// countConnections counts the connections accepted by a test server
+func countConnections(server *httptest.Server) *atomic.Int32 {
+	var conns atomic.Int32
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	return &conns
+}
+
+// getConcurrently issues n concurrent requests and returns the protocols used
+func getConcurrently(t *testing.T, client *http.Client, url string, n int) []string {
+	t.Helper()
+
+	protocols := make([]string, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := client.Get(url)
+			if err != nil {
+				t.Errorf("Request failed: %v", err)
+				return
+			}
+			resp.Body.Close()
+			protocols[i] = resp.Proto
+		}(i)
+	}
+	wg.Wait()
+	return protocols
+}
+
+func TestFactory_HTTP2_TLS(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.EnableHTTP2 = true
+	conns := countConnections(server)
+	server.StartTLS()
+	defer server.Close()
+
+	caFile := writePEM(t, t.TempDir(), "ca.pem", "CERTIFICATE", server.Certificate().Raw)
+	factory, err := NewFactory(Options{HTTP2: true, TLS: TLSOptions{CAFile: caFile}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, proto := range getConcurrently(t, factory.DownloadClient(), server.URL, 4) {
+		if proto != "HTTP/2.0" {
+			t.Errorf("Expected HTTP/2.0, got: %s", proto)
+		}
+	}
+	if n := conns.Load(); n != 1 {
+		t.Errorf("Expected requests to share one connection, got %d", n)
+	}
+}
+
+func TestFactory_HTTP2_Cleartext(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Config.Protocols = new(http.Protocols)
+	server.Config.Protocols.SetHTTP1(true)
+	server.Config.Protocols.SetUnencryptedHTTP2(true)
+	conns := countConnections(server)
+	server.Start()
+	defer server.Close()
+
+	factory, err := NewFactory(Options{HTTP2: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, proto := range getConcurrently(t, factory.UploadClient(), server.URL, 4) {
+		if proto != "HTTP/2.0" {
+			t.Errorf("Expected h2c to report HTTP/2.0, got: %s", proto)
+		}
+	}
+	if n := conns.Load(); n != 1 {
+		t.Errorf("Expected requests to share one connection, got %d", n)
+	}
+}
+
+func TestFactory_HTTP1Default(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	caFile := writePEM(t, t.TempDir(), "ca.pem", "CERTIFICATE", server.Certificate().Raw)
+	factory, err := NewFactory(Options{TLS: TLSOptions{CAFile: caFile}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, proto := range getConcurrently(t, factory.HTTPClient(), server.URL, 1) {
+		if proto != "HTTP/1.1" {
+			t.Errorf("Expected HTTP/1.1 without --http2, got: %s", proto)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/user/speed-test-go/pkg/types"
+)
+
+// DescribeInterface returns the local address, interface name and MAC address
+// of the most recent connection the factory dialed directly to host. It fails
+// when no such connection was made, e.g. because requests went through a
+// proxy, so only addresses the test actually used are reported.
+func (f *Factory) DescribeInterface(host string) (*types.InterfaceInfo, error) {
+	ip := f.conns.lookup(host)
+	if ip == nil {
+		return nil, fmt.Errorf("no direct connection to %s", host)
+	}
+
+	info := &types.InterfaceInfo{
+		InternalIP: ip.String(),
+	}
+
+	iface, err := interfaceForIP(ip)
+	if err != nil {
+		// The address is still useful without the interface details
+		return info, nil
+	}
+	info.Name = iface.Name
+	info.MACAddr = iface.HardwareAddr.String()
+
+	return info, nil
+}
+
+// localAddrs records the local address of the most recent connection dialed
+// to each host. A nil set records nothing.
+type localAddrs struct {
+	mu    sync.Mutex
+	addrs map[string]net.IP
+}
+
+func newLocalAddrs() *localAddrs {
+	return &localAddrs{addrs: make(map[string]net.IP)}
+}
+
+// record notes the local address of conn, dialed to addr
+func (l *localAddrs) record(addr string, conn net.Conn) {
+	tcpAddr, ok := conn.LocalAddr().(*net.TCPAddr)
+	if l == nil || !ok {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.addrs[hostname(addr)] = tcpAddr.IP
+}
+
+// lookup returns the local address of the last connection to host, or nil
+func (l *localAddrs) lookup(host string) net.IP {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.addrs[hostname(host)]
+}
+
+// hostname strips the port from addr, since connections to one server may
+// use several ports
+func hostname(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	return strings.ToLower(strings.Trim(addr, "[]"))
+}
+
+// interfaceForIP finds the interface that owns ip
+func interfaceForIP(ip net.IP) (*net.Interface, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list interfaces: %w", err)
+	}
+
+	for i := range ifaces {
+		addrs, err := ifaces[i].Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+				return &ifaces[i], nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("no interface owns address %s", ip)
+}
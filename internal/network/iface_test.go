@@ -0,0 +1,111 @@
+package network
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// dialLoopback connects factory to a local listener and returns its address
+func dialLoopback(t *testing.T, factory *Factory) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	conn, err := factory.DialContext(context.Background(), "tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	conn.Close()
+	return ln.Addr().String()
+}
+
+func TestDescribeInterface_Loopback(t *testing.T) {
+	name := loopbackInterface(t)
+	factory, err := NewFactory(Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	addr := dialLoopback(t, factory)
+
+	info, err := factory.DescribeInterface(addr)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if info.InternalIP != "127.0.0.1" {
+		t.Errorf("Expected internal IP 127.0.0.1, got: %s", info.InternalIP)
+	}
+	if info.Name != name {
+		t.Errorf("Expected interface %s, got: %s", name, info.Name)
+	}
+}
+
+func TestDescribeInterface_Bound(t *testing.T) {
+	factory, err := NewFactory(Options{SourceAddr: "127.0.0.1"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	addr := dialLoopback(t, factory)
+
+	info, err := factory.DescribeInterface(addr)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if info.InternalIP != "127.0.0.1" {
+		t.Errorf("Expected internal IP 127.0.0.1, got: %s", info.InternalIP)
+	}
+}
+
+func TestDescribeInterface_HostWithoutPort(t *testing.T) {
+	factory, err := NewFactory(Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	dialLoopback(t, factory)
+
+	// Connections to other ports of the host count too
+	info, err := factory.DescribeInterface("127.0.0.1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if info.InternalIP != "127.0.0.1" {
+		t.Errorf("Expected internal IP 127.0.0.1, got: %s", info.InternalIP)
+	}
+}
+
+func TestDescribeInterface_NotConnected(t *testing.T) {
+	factory, err := NewFactory(Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := factory.DescribeInterface("127.0.0.1:8080"); err == nil {
+		t.Error("Expected an error for a host that was never dialed")
+	}
+}
+
+func TestDescribeInterface_Proxy(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer proxy.Close()
+
+	factory, err := NewFactory(Options{Proxy: proxy.URL})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp, err := factory.HTTPClient().Get("http://speedtest.invalid/")
+	if err != nil {
+		t.Fatalf("Request through the proxy failed: %v", err)
+	}
+	resp.Body.Close()
+
+	// Only the proxy was dialed, so the server's local address is unknown
+	if info, err := factory.DescribeInterface("speedtest.invalid"); err == nil {
+		t.Errorf("Expected no local address behind a proxy, got: %+v", info)
+	}
+}
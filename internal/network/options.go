@@ -0,0 +1,127 @@
+package network
+
+import (
+	"fmt"
+	"net"
+)
+
+// Family constrains connections to one IP address family
+type Family string
+
+const (
+	FamilyAny  Family = ""
+	FamilyIPv4 Family = "ipv4"
+	FamilyIPv6 Family = "ipv6"
+)
+
+// Network returns the family-specific variant of a network such as "tcp"
+func (f Family) Network(network string) string {
+	switch f {
+	case FamilyIPv4:
+		return network + "4"
+	case FamilyIPv6:
+		return network + "6"
+	default:
+		return network
+	}
+}
+
+// Matches reports whether ip belongs to the family
+func (f Family) Matches(ip net.IP) bool {
+	switch f {
+	case FamilyIPv4:
+		return ip.To4() != nil
+	case FamilyIPv6:
+		return ip.To4() == nil
+	default:
+		return true
+	}
+}
+
+// FamilyOf returns the address family of ip
+func FamilyOf(ip net.IP) Family {
+	if ip.To4() != nil {
+		return FamilyIPv4
+	}
+	return FamilyIPv6
+}
+
+// Options configures how connections are made by a Factory
+type Options struct {
+	Interface  string // bind connections to an address of this interface
+	SourceAddr string // bind connections to this local IP address
+	Family     Family // restrict connections to one address family
+	Proxy      string // proxy URL (http, https, socks5); empty uses the environment
+	DNSServer  string // resolver address, optionally prefixed with udp:// or tcp://
+	TLS        TLSOptions
+	HTTP2      bool // multiplex requests over one HTTP/2 connection per host (h2c for http URLs)
+}
+
+// resolveLocalAddr determines the local IP to bind to for the given options
+func resolveLocalAddr(opts Options) (net.IP, error) {
+	if opts.SourceAddr != "" && opts.Interface != "" {
+		return nil, fmt.Errorf("interface and source address cannot both be set")
+	}
+
+	switch opts.Family {
+	case FamilyAny, FamilyIPv4, FamilyIPv6:
+	default:
+		return nil, fmt.Errorf("unknown address family %q", opts.Family)
+	}
+
+	if opts.SourceAddr != "" {
+		ip := net.ParseIP(opts.SourceAddr)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid source address %q", opts.SourceAddr)
+		}
+		if !opts.Family.Matches(ip) {
+			return nil, fmt.Errorf("source address %s is not %s", ip, opts.Family)
+		}
+		return ip, nil
+	}
+
+	if opts.Interface != "" {
+		iface, err := net.InterfaceByName(opts.Interface)
+		if err != nil {
+			return nil, fmt.Errorf("interface %s not found: %w", opts.Interface, err)
+		}
+		ip, err := interfaceAddr(iface, opts.Family)
+		if err != nil {
+			return nil, err
+		}
+		return ip, nil
+	}
+
+	return nil, nil
+}
+
+// interfaceAddr picks a usable address of the family from an interface,
+// preferring IPv4 when any family is allowed
+func interfaceAddr(iface *net.Interface, family Family) (net.IP, error) {
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list addresses of %s: %w", iface.Name, err)
+	}
+
+	var fallback net.IP
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.IsLinkLocalUnicast() || !family.Matches(ipNet.IP) {
+			continue
+		}
+		if ipNet.IP.To4() != nil {
+			return ipNet.IP, nil
+		}
+		if fallback == nil {
+			fallback = ipNet.IP
+		}
+	}
+
+	if fallback == nil {
+		if family != FamilyAny {
+			return nil, fmt.Errorf("interface %s has no usable %s address", iface.Name, family)
+		}
+		return nil, fmt.Errorf("interface %s has no usable address", iface.Name)
+	}
+	return fallback, nil
+}
@@ -0,0 +1,173 @@
+package network
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// loopbackInterface returns the name of the loopback interface, if any
+func loopbackInterface(t *testing.T) string {
+	t.Helper()
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		t.Skipf("Cannot list interfaces: %v", err)
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			return iface.Name
+		}
+	}
+	t.Skip("No loopback interface")
+	return ""
+}
+
+func TestNewFactory_Default(t *testing.T) {
+	factory, err := NewFactory(Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if factory.LocalAddr() != nil {
+		t.Errorf("Expected no local address, got: %v", factory.LocalAddr())
+	}
+}
+
+func TestNewFactory_SourceAddr(t *testing.T) {
+	factory, err := NewFactory(Options{SourceAddr: "127.0.0.1"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !factory.LocalAddr().Equal(net.ParseIP("127.0.0.1")) {
+		t.Errorf("Expected local address 127.0.0.1, got: %v", factory.LocalAddr())
+	}
+	if factory.Options().SourceAddr != "127.0.0.1" {
+		t.Errorf("Expected options to record source address, got: %+v", factory.Options())
+	}
+}
+
+func TestNewFactory_Interface(t *testing.T) {
+	name := loopbackInterface(t)
+
+	factory, err := NewFactory(Options{Interface: name})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if ip := factory.LocalAddr(); ip == nil || !ip.IsLoopback() {
+		t.Errorf("Expected loopback address for %s, got: %v", name, ip)
+	}
+}
+
+func TestNewFactory_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{name: "invalid source", opts: Options{SourceAddr: "not-an-ip"}},
+		{name: "unknown interface", opts: Options{Interface: "does-not-exist0"}},
+		{name: "both set", opts: Options{Interface: "lo", SourceAddr: "127.0.0.1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewFactory(tt.opts); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
+
+func TestFactory_BoundSource(t *testing.T) {
+	var remote string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remote = r.RemoteAddr
+	}))
+	defer server.Close()
+
+	factory, err := NewFactory(Options{SourceAddr: "127.0.0.1"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	resp, err := factory.HTTPClient().Get(server.URL)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	host, _, _ := net.SplitHostPort(remote)
+	if host != "127.0.0.1" {
+		t.Errorf("Expected request from 127.0.0.1, got: %s", remote)
+	}
+}
+
+func TestFamily_Network(t *testing.T) {
+	tests := []struct {
+		family Family
+		want   string
+	}{
+		{FamilyAny, "tcp"},
+		{FamilyIPv4, "tcp4"},
+		{FamilyIPv6, "tcp6"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.family.Network("tcp"); got != tt.want {
+			t.Errorf("Family(%q).Network(tcp) = %s, want %s", tt.family, got, tt.want)
+		}
+	}
+}
+
+func TestFamily_Matches(t *testing.T) {
+	v4 := net.ParseIP("192.0.2.1")
+	v6 := net.ParseIP("2001:db8::1")
+
+	if !FamilyIPv4.Matches(v4) || FamilyIPv4.Matches(v6) {
+		t.Error("Expected FamilyIPv4 to match only IPv4 addresses")
+	}
+	if !FamilyIPv6.Matches(v6) || FamilyIPv6.Matches(v4) {
+		t.Error("Expected FamilyIPv6 to match only IPv6 addresses")
+	}
+	if !FamilyAny.Matches(v4) || !FamilyAny.Matches(v6) {
+		t.Error("Expected FamilyAny to match every address")
+	}
+
+	if FamilyOf(v4) != FamilyIPv4 || FamilyOf(v6) != FamilyIPv6 {
+		t.Error("FamilyOf() returned the wrong family")
+	}
+}
+
+func TestNewFactory_FamilyErrors(t *testing.T) {
+	if _, err := NewFactory(Options{SourceAddr: "127.0.0.1", Family: FamilyIPv6}); err == nil {
+		t.Error("Expected error for IPv4 source address with IPv6 family")
+	}
+	if _, err := NewFactory(Options{Family: "ipx"}); err == nil {
+		t.Error("Expected error for unknown family")
+	}
+}
+
+func TestFactory_FamilyRestriction(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	factory, err := NewFactory(Options{Family: FamilyIPv4})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp, err := factory.HTTPClient().Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected IPv4 request to 127.0.0.1 to succeed: %v", err)
+	}
+	resp.Body.Close()
+
+	factory, err = NewFactory(Options{Family: FamilyIPv6})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if resp, err := factory.HTTPClient().Get(server.URL); err == nil {
+		resp.Body.Close()
+		t.Error("Expected IPv6-only client to refuse an IPv4 address")
+	}
+}
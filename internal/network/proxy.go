@@ -0,0 +1,314 @@
+package network
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseProxy validates an explicit proxy URL.
+// Supported schemes are http, https, socks5 and socks5h; credentials may be
+// given as user:password in the URL.
+func parseProxy(raw string) (*url.URL, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL %q: %w", raw, err)
+	}
+
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q (use http, https, socks5 or socks5h)", u.Scheme)
+	}
+
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q: missing host", raw)
+	}
+
+	return u, nil
+}
+
+// proxyFunc returns the proxy selection used by every transport. An explicit
+// proxy applies to all requests except hosts listed in NO_PROXY; otherwise
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY from the environment are used.
+func proxyFunc(proxy *url.URL) func(*http.Request) (*url.URL, error) {
+	if proxy == nil {
+		return http.ProxyFromEnvironment
+	}
+
+	noProxy := noProxyList()
+	return func(req *http.Request) (*url.URL, error) {
+		if bypassProxy(req.URL.Host, noProxy) {
+			return nil, nil
+		}
+		return proxy, nil
+	}
+}
+
+// noProxyList returns the NO_PROXY list from the environment
+func noProxyList() string {
+	if noProxy := os.Getenv("NO_PROXY"); noProxy != "" {
+		return noProxy
+	}
+	return os.Getenv("no_proxy")
+}
+
+// bypassProxy reports whether host matches an entry of a NO_PROXY list.
+// Entries may be "*", hostnames (matching subdomains too), ".domain"
+// suffixes, IP addresses or CIDR ranges, optionally with a port.
+func bypassProxy(host, noProxy string) bool {
+	if noProxy == "" {
+		return false
+	}
+
+	hostname, port := host, ""
+	if h, p, err := net.SplitHostPort(host); err == nil {
+		hostname, port = h, p
+	}
+	hostname = strings.ToLower(hostname)
+	ip := net.ParseIP(hostname)
+
+	for _, entry := range strings.Split(noProxy, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			return true
+		}
+
+		if _, cidr, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && cidr.Contains(ip) {
+				return true
+			}
+			continue
+		}
+
+		entryHost, entryPort := entry, ""
+		if h, p, err := net.SplitHostPort(entry); err == nil {
+			entryHost, entryPort = h, p
+		}
+		if entryPort != "" && entryPort != port {
+			continue
+		}
+
+		if entryIP := net.ParseIP(entryHost); entryIP != nil {
+			if ip != nil && entryIP.Equal(ip) {
+				return true
+			}
+			continue
+		}
+
+		entryHost = strings.TrimPrefix(entryHost, ".")
+		if hostname == entryHost || strings.HasSuffix(hostname, "."+entryHost) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// dialProxy opens a raw connection to addr tunnelled through proxy, with a
+// CONNECT request for http and https proxies or a SOCKS5 CONNECT for socks5
+// and socks5h. The proxy resolves addr, as it does for HTTP requests.
+func dialProxy(ctx context.Context, dial dialFunc, proxy *url.URL, tlsConfig *tls.Config, addr string) (net.Conn, error) {
+	conn, err := dial(ctx, "tcp", proxyAddr(proxy))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to proxy %s: %w", proxy.Host, err)
+	}
+
+	// Abort the handshake once ctx ends
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Unix(1, 0)) })
+
+	tunnel := conn
+	switch proxy.Scheme {
+	case "https":
+		config := tlsConfig.Clone()
+		if config == nil {
+			config = &tls.Config{}
+		}
+		config.ServerName = proxy.Hostname()
+		tlsConn := tls.Client(conn, config)
+		if err = tlsConn.HandshakeContext(ctx); err == nil {
+			tunnel, err = connectHTTP(tlsConn, proxy, addr)
+		}
+	case "http":
+		tunnel, err = connectHTTP(conn, proxy, addr)
+	default:
+		err = connectSOCKS5(conn, proxy, addr)
+	}
+
+	if !stop() && err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s failed to connect to %s: %w", proxy.Host, addr, err)
+	}
+	return tunnel, nil
+}
+
+// proxyAddr returns the proxy's host and port, using the scheme's default port
+func proxyAddr(proxy *url.URL) string {
+	if proxy.Port() != "" {
+		return proxy.Host
+	}
+	port := "1080"
+	switch proxy.Scheme {
+	case "http":
+		port = "80"
+	case "https":
+		port = "443"
+	}
+	return net.JoinHostPort(proxy.Hostname(), port)
+}
+
+// connectHTTP asks an HTTP proxy for a tunnel to addr over conn
+func connectHTTP(conn net.Conn, proxy *url.URL, addr string) (net.Conn, error) {
+	req := &http.Request{
+		Method: "CONNECT",
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if proxy.User != nil {
+		pass, _ := proxy.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(proxy.User.Username() + ":" + pass))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("CONNECT returned %s", resp.Status)
+	}
+
+	// Keep any bytes the server sent right after the response
+	if reader.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, reader: reader}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn reads through a reader that may hold data already received
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+// connectSOCKS5 performs a SOCKS5 handshake over conn, authenticating with
+// the proxy URL's credentials if any, and connects to addr (RFC 1928, 1929)
+func connectSOCKS5(conn net.Conn, proxy *url.URL, addr string) error {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return fmt.Errorf("invalid port %q", portStr)
+	}
+
+	// Greeting: offer username/password authentication when credentials are set
+	method := byte(0x00)
+	if proxy.User != nil {
+		method = 0x02
+	}
+	if _, err := conn.Write([]byte{0x05, 0x01, method}); err != nil {
+		return err
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[0] != 0x05 || reply[1] != method {
+		return errors.New("SOCKS5 proxy refused the authentication method")
+	}
+
+	if method == 0x02 {
+		user := proxy.User.Username()
+		pass, _ := proxy.User.Password()
+		if len(user) > 255 || len(pass) > 255 {
+			return errors.New("SOCKS5 credentials are too long")
+		}
+		auth := append([]byte{0x01, byte(len(user))}, user...)
+		auth = append(append(auth, byte(len(pass))), pass...)
+		if _, err := conn.Write(auth); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return err
+		}
+		if reply[1] != 0x00 {
+			return errors.New("SOCKS5 authentication failed")
+		}
+	}
+
+	// Request: VER CMD RSV ATYP DST.ADDR DST.PORT
+	req := []byte{0x05, 0x01, 0x00}
+	if ip := net.ParseIP(host); ip.To4() != nil {
+		req = append(append(req, 0x01), ip.To4()...)
+	} else if ip != nil {
+		req = append(append(req, 0x04), ip.To16()...)
+	} else {
+		if len(host) > 255 {
+			return fmt.Errorf("host name %q is too long", host)
+		}
+		req = append(append(req, 0x03, byte(len(host))), host...)
+	}
+	req = binary.BigEndian.AppendUint16(req, uint16(port))
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	// Reply: VER REP RSV ATYP BND.ADDR BND.PORT
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	}
+	if header[1] != 0x00 {
+		return fmt.Errorf("SOCKS5 connect failed with code %d", header[1])
+	}
+	var skip int
+	switch header[3] {
+	case 0x01:
+		skip = net.IPv4len + 2
+	case 0x04:
+		skip = net.IPv6len + 2
+	case 0x03:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return err
+		}
+		skip = int(length[0]) + 2
+	default:
+		return fmt.Errorf("SOCKS5 reply has unknown address type %d", header[3])
+	}
+	_, err = io.ReadFull(conn, make([]byte, skip))
+	return err
+}
@@ -0,0 +1,116 @@
+package network
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// TLSOptions configures certificate verification for HTTPS connections
+type TLSOptions struct {
+	CAFile   string   // PEM bundle of extra trusted CA certificates
+	CertFile string   // PEM client certificate for mutual TLS
+	KeyFile  string   // PEM private key for CertFile
+	Insecure bool     // skip certificate verification
+	Pins     []string // SHA-256 public key pins, as "sha256/<base64>"
+}
+
+// newTLSConfig builds the client TLS configuration for opts
+func newTLSConfig(opts TLSOptions) (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: opts.Insecure,
+	}
+
+	if opts.CAFile != "" {
+		pool, err := loadCAFile(opts.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = pool
+	}
+
+	if opts.CertFile != "" || opts.KeyFile != "" {
+		if opts.CertFile == "" || opts.KeyFile == "" {
+			return nil, fmt.Errorf("client certificate and key must be given together")
+		}
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	if len(opts.Pins) > 0 {
+		pins, err := parsePins(opts.Pins)
+		if err != nil {
+			return nil, err
+		}
+		// VerifyConnection also runs in insecure mode, so pins always apply
+		config.VerifyConnection = func(state tls.ConnectionState) error {
+			return verifyPins(state.PeerCertificates, pins)
+		}
+	}
+
+	return config, nil
+}
+
+// loadCAFile returns the system roots extended with the certificates in path
+func loadCAFile(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file: %w", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in CA file %s", path)
+	}
+	return pool, nil
+}
+
+// parsePins decodes public key pins given as "sha256/<base64>", the curl-style
+// "sha256//<base64>" or bare base64 digests
+func parsePins(raw []string) ([][]byte, error) {
+	pins := make([][]byte, 0, len(raw))
+	for _, pin := range raw {
+		encoded := strings.TrimSpace(pin)
+		encoded = strings.TrimPrefix(encoded, "sha256/")
+		encoded = strings.TrimPrefix(encoded, "/")
+
+		digest, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(digest) != sha256.Size {
+			return nil, fmt.Errorf("invalid public key pin %q: expected a base64 SHA-256 digest", pin)
+		}
+		pins = append(pins, digest)
+	}
+	return pins, nil
+}
+
+// verifyPins succeeds if any certificate in the chain has a pinned public key
+func verifyPins(certs []*x509.Certificate, pins [][]byte) error {
+	for _, cert := range certs {
+		digest := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+		for _, pin := range pins {
+			if bytes.Equal(digest[:], pin) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("server public key does not match any pin")
+}
+
+// PublicKeyPin returns the pin of a certificate's public key in the form
+// accepted by TLSOptions.Pins
+func PublicKeyPin(cert *x509.Certificate) string {
+	digest := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return "sha256/" + base64.StdEncoding.EncodeToString(digest[:])
+}
@@ -0,0 +1,182 @@
+package network
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writePEM writes a single PEM block to a file in dir and returns its path
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+// clientCertificate generates a self-signed client certificate and returns
+// the certificate and key file paths
+func clientCertificate(t *testing.T) (*x509.Certificate, string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "speed-test client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to encode key: %v", err)
+	}
+
+	dir := t.TempDir()
+	return cert, writePEM(t, dir, "client.pem", "CERTIFICATE", der), writePEM(t, dir, "client-key.pem", "EC PRIVATE KEY", keyDER)
+}
+
+// get requests url with a client from a factory configured with opts
+func get(t *testing.T, opts TLSOptions, url string) error {
+	t.Helper()
+
+	factory, err := NewFactory(Options{TLS: opts})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp, err := factory.HTTPClient().Get(url)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func TestFactory_TLS_CAFile(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	if err := get(t, TLSOptions{}, server.URL); err == nil {
+		t.Error("Expected untrusted certificate to be rejected")
+	}
+
+	caFile := writePEM(t, t.TempDir(), "ca.pem", "CERTIFICATE", server.Certificate().Raw)
+	if err := get(t, TLSOptions{CAFile: caFile}, server.URL); err != nil {
+		t.Errorf("Expected certificate from CA file to be trusted: %v", err)
+	}
+}
+
+func TestFactory_TLS_Insecure(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	factory, err := NewFactory(Options{TLS: TLSOptions{Insecure: true}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !factory.Insecure() {
+		t.Error("Expected factory to report insecure mode")
+	}
+
+	if err := get(t, TLSOptions{Insecure: true}, server.URL); err != nil {
+		t.Errorf("Expected insecure request to succeed: %v", err)
+	}
+}
+
+func TestFactory_TLS_Pins(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	caFile := writePEM(t, t.TempDir(), "ca.pem", "CERTIFICATE", server.Certificate().Raw)
+	pin := PublicKeyPin(server.Certificate())
+	otherPin := "sha256/47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="
+
+	if err := get(t, TLSOptions{CAFile: caFile, Pins: []string{otherPin, pin}}, server.URL); err != nil {
+		t.Errorf("Expected matching pin to be accepted: %v", err)
+	}
+	if err := get(t, TLSOptions{CAFile: caFile, Pins: []string{otherPin}}, server.URL); err == nil {
+		t.Error("Expected mismatched pin to be rejected")
+	}
+
+	// Pins still apply when verification is disabled
+	if err := get(t, TLSOptions{Insecure: true, Pins: []string{otherPin}}, server.URL); err == nil {
+		t.Error("Expected mismatched pin to be rejected in insecure mode")
+	}
+}
+
+func TestFactory_TLS_ClientCertificate(t *testing.T) {
+	cert, certFile, keyFile := clientCertificate(t)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	server.TLS = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  pool,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	caFile := writePEM(t, t.TempDir(), "ca.pem", "CERTIFICATE", server.Certificate().Raw)
+
+	if err := get(t, TLSOptions{CAFile: caFile}, server.URL); err == nil {
+		t.Error("Expected request without client certificate to be rejected")
+	}
+	if err := get(t, TLSOptions{CAFile: caFile, CertFile: certFile, KeyFile: keyFile}, server.URL); err != nil {
+		t.Errorf("Expected request with client certificate to succeed: %v", err)
+	}
+}
+
+func TestNewFactory_TLSErrors(t *testing.T) {
+	_, certFile, _ := clientCertificate(t)
+	emptyFile := filepath.Join(t.TempDir(), "empty.pem")
+	if err := os.WriteFile(emptyFile, nil, 0o600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		opts TLSOptions
+	}{
+		{name: "missing CA file", opts: TLSOptions{CAFile: "/does/not/exist.pem"}},
+		{name: "empty CA file", opts: TLSOptions{CAFile: emptyFile}},
+		{name: "cert without key", opts: TLSOptions{CertFile: certFile}},
+		{name: "invalid pin", opts: TLSOptions{Pins: []string{"sha256/not-base64"}}},
+		{name: "short pin", opts: TLSOptions{Pins: []string{"sha256/AAAA"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewFactory(Options{TLS: tt.opts}); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
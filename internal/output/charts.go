@@ -0,0 +1,181 @@
+package output
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/user/speed-test-go/pkg/types"
+)
+
+const (
+	// sparklineWidth is the most characters a sparkline uses; longer series
+	// are averaged into this many columns
+	sparklineWidth = 40
+
+	// histogramBuckets is the most rows of a latency histogram
+	histogramBuckets = 6
+
+	// histogramWidth is the length of the longest histogram bar
+	histogramWidth = 20
+)
+
+// Levels of a sparkline from lowest to highest
+var (
+	unicodeLevels = []rune("▁▂▃▄▅▆▇█")
+	asciiLevels   = []rune("_.-:=+*#")
+)
+
+// SupportsUnicode reports whether the terminal's locale is UTF-8, so block
+// characters can be drawn
+func SupportsUnicode() bool {
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if value := os.Getenv(name); value != "" {
+			value = strings.ToLower(value)
+			return strings.Contains(value, "utf-8") || strings.Contains(value, "utf8")
+		}
+	}
+	return false
+}
+
+// sparkline draws values as a single line of bars scaled from zero to the
+// largest value
+func sparkline(values []float64, unicode bool) string {
+	levels := asciiLevels
+	if unicode {
+		levels = unicodeLevels
+	}
+
+	columns := resample(values, sparklineWidth)
+	peak := 0.0
+	for _, v := range columns {
+		peak = max(peak, v)
+	}
+
+	var sb strings.Builder
+	for _, v := range columns {
+		level := 0
+		if peak > 0 {
+			level = int(math.Round(v / peak * float64(len(levels)-1)))
+		}
+		sb.WriteRune(levels[min(max(level, 0), len(levels)-1)])
+	}
+	return sb.String()
+}
+
+// resample averages values into at most width columns
+func resample(values []float64, width int) []float64 {
+	if len(values) <= width {
+		return values
+	}
+
+	columns := make([]float64, width)
+	for i := range columns {
+		start := i * len(values) / width
+		end := (i + 1) * len(values) / width
+		sum := 0.0
+		for _, v := range values[start:end] {
+			sum += v
+		}
+		columns[i] = sum / float64(end-start)
+	}
+	return columns
+}
+
+// histogramRow is a latency range and the number of probes within it
+type histogramRow struct {
+	low, high float64 // milliseconds
+	count     int
+}
+
+// latencyHistogram sorts latencies into equal-width buckets between the
+// lowest and highest
+func latencyHistogram(latencies []float64) []histogramRow {
+	if len(latencies) == 0 {
+		return nil
+	}
+
+	low, high := latencies[0], latencies[0]
+	for _, l := range latencies {
+		low, high = min(low, l), max(high, l)
+	}
+
+	buckets := min(histogramBuckets, len(latencies))
+	if high == low {
+		buckets = 1
+	}
+	width := (high - low) / float64(buckets)
+
+	rows := make([]histogramRow, buckets)
+	for i := range rows {
+		rows[i].low = low + float64(i)*width
+		rows[i].high = low + float64(i+1)*width
+	}
+	for _, l := range latencies {
+		i := buckets - 1
+		if width > 0 {
+			i = min(int((l-low)/width), buckets-1)
+		}
+		rows[i].count++
+	}
+	return rows
+}
+
+// histogramBar draws count as a bar scaled to the largest bucket
+func histogramBar(count, largest int, unicode bool) string {
+	block := "#"
+	if unicode {
+		block = "█"
+	}
+	n := count * histogramWidth / largest
+	if count > 0 && n == 0 {
+		n = 1
+	}
+	return strings.Repeat(block, n)
+}
+
+// formatCharts draws the throughput of each phase over time as a sparkline
+// and the distribution of latency probes as a histogram, using the samples
+// recorded in result. Phases without samples are left out.
+func (f *Formatter) formatCharts(result *types.SpeedTestResult) string {
+	var sb strings.Builder
+
+	for _, phase := range []struct {
+		label  string
+		series []types.TransferSample
+	}{{"  Download", result.Download.Series}, {"    Upload", result.Upload.Series}} {
+		if len(phase.series) == 0 {
+			continue
+		}
+		rates := make([]float64, len(phase.series))
+		peak := 0.0
+		for i, sample := range phase.series {
+			rates[i] = sample.Rate
+			peak = max(peak, sample.Rate)
+		}
+		sb.WriteString(fmt.Sprintf("%s   %s  %s peak\n", phase.label, sparkline(rates, f.unicode), formatSpeed(int64(peak), f.useBytes)))
+	}
+
+	latencies := make([]float64, len(result.Ping.Series))
+	for i, sample := range result.Ping.Series {
+		latencies[i] = sample.Latency
+	}
+	rows := latencyHistogram(latencies)
+	largest := 0
+	for _, row := range rows {
+		largest = max(largest, row.count)
+	}
+
+	label := "   Latency"
+	for _, row := range rows {
+		bucket := fmt.Sprintf("%.1f-%.1f ms", row.low, row.high)
+		sb.WriteString(fmt.Sprintf("%s   %-15s %s %d\n", label, bucket, histogramBar(row.count, largest, f.unicode), row.count))
+		label = "          "
+	}
+
+	if sb.Len() == 0 {
+		return ""
+	}
+	return "\n" + sb.String()
+}
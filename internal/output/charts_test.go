@@ -0,0 +1,172 @@
+package output
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/speed-test-go/pkg/types"
+)
+
+func TestSparkline(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []float64
+		unicode bool
+		want    string
+	}{
+		{"unicode", []float64{0, 1, 2, 3, 4, 5, 6, 7}, true, "▁▂▃▄▅▆▇█"},
+		{"ascii", []float64{0, 1, 2, 3, 4, 5, 6, 7}, false, "_.-:=+*#"},
+		{"scaled to peak", []float64{50, 100, 100}, true, "▅██"},
+		{"all zero", []float64{0, 0}, false, "__"},
+		{"empty", nil, true, ""},
+	}
+
+	for _, tt := range tests {
+		if got := sparkline(tt.values, tt.unicode); got != tt.want {
+			t.Errorf("%s: sparkline(%v) = %q, want %q", tt.name, tt.values, got, tt.want)
+		}
+	}
+}
+
+func TestSparkline_Resample(t *testing.T) {
+	values := make([]float64, 4*sparklineWidth)
+	for i := range values {
+		values[i] = float64(i / 4)
+	}
+
+	line := []rune(sparkline(values, true))
+	if len(line) != sparklineWidth {
+		t.Fatalf("Expected %d columns, got %d", sparklineWidth, len(line))
+	}
+	if line[0] != '▁' || line[len(line)-1] != '█' {
+		t.Errorf("Expected a rising line, got %q", string(line))
+	}
+}
+
+func TestLatencyHistogram(t *testing.T) {
+	rows := latencyHistogram([]float64{10, 11, 12, 13, 14, 15, 16, 40})
+	if len(rows) != histogramBuckets {
+		t.Fatalf("Expected %d buckets, got %d", histogramBuckets, len(rows))
+	}
+	if rows[0].low != 10 || rows[len(rows)-1].high != 40 {
+		t.Errorf("Expected buckets from 10 to 40 ms, got %+v", rows)
+	}
+	// 10-15 ms holds five probes, the outlier lands in the last bucket
+	if rows[0].count != 5 || rows[1].count != 2 || rows[len(rows)-1].count != 1 {
+		t.Errorf("Unexpected bucket counts: %+v", rows)
+	}
+
+	total := 0
+	for _, row := range rows {
+		total += row.count
+	}
+	if total != 8 {
+		t.Errorf("Expected every probe in a bucket, got %d", total)
+	}
+}
+
+func TestLatencyHistogram_Equal(t *testing.T) {
+	rows := latencyHistogram([]float64{12, 12, 12})
+	if len(rows) != 1 || rows[0].count != 3 {
+		t.Errorf("Expected a single bucket of 3, got %+v", rows)
+	}
+	if rows := latencyHistogram(nil); rows != nil {
+		t.Errorf("Expected no buckets without probes, got %+v", rows)
+	}
+}
+
+func TestHistogramBar(t *testing.T) {
+	if got := histogramBar(10, 10, true); got != strings.Repeat("█", histogramWidth) {
+		t.Errorf("Expected a full bar, got %q", got)
+	}
+	if got := histogramBar(5, 10, false); got != strings.Repeat("#", histogramWidth/2) {
+		t.Errorf("Expected a half ASCII bar, got %q", got)
+	}
+	if got := histogramBar(1, 1000, false); got != "#" {
+		t.Errorf("Expected small counts to stay visible, got %q", got)
+	}
+}
+
+func TestSupportsUnicode(t *testing.T) {
+	tests := []struct {
+		lcAll, lang string
+		want        bool
+	}{
+		{"", "en_US.UTF-8", true},
+		{"", "de_DE.utf8", true},
+		{"C", "en_US.UTF-8", false},
+		{"", "C", false},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("LC_ALL", tt.lcAll)
+		t.Setenv("LC_CTYPE", "")
+		t.Setenv("LANG", tt.lang)
+		if got := SupportsUnicode(); got != tt.want {
+			t.Errorf("LC_ALL=%q LANG=%q: SupportsUnicode() = %v, want %v", tt.lcAll, tt.lang, got, tt.want)
+		}
+	}
+}
+
+func TestFormatter_Format_Charts(t *testing.T) {
+	result := &types.SpeedTestResult{
+		Timestamp: time.Now(),
+		Ping: types.PingResult{Latency: 12, Series: []types.LatencySample{
+			{Elapsed: 10, Latency: 10}, {Elapsed: 20, Latency: 12}, {Elapsed: 30, Latency: 16},
+		}},
+		Download: types.TransferResult{Bandwidth: 12_500_000, Series: []types.TransferSample{
+			{Elapsed: 100, Rate: 6_250_000}, {Elapsed: 200, Rate: 12_500_000},
+		}},
+	}
+
+	f := NewFormatter(false, false, false)
+	if output := f.Format(result); strings.Contains(output, "Latency") {
+		t.Errorf("Expected no charts unless enabled, got:\n%s", output)
+	}
+
+	f.SetCharts(true)
+	output := f.Format(result)
+	for _, want := range []string{
+		"  Download   ▅█  100.00 Mbps peak\n",
+		"   Latency   10.0-12.0 ms    " + strings.Repeat("█", histogramWidth) + " 1\n",
+		"             14.0-16.0 ms    " + strings.Repeat("█", histogramWidth) + " 1\n",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, output)
+		}
+	}
+	// The upload recorded no samples
+	if strings.Count(output, "Upload") != 1 {
+		t.Errorf("Expected no upload chart, got:\n%s", output)
+	}
+
+	f.SetUnicode(false)
+	if output := f.Format(result); !strings.Contains(output, "  Download   =#  ") || strings.Contains(output, "█") {
+		t.Errorf("Expected ASCII charts, got:\n%s", output)
+	}
+}
+
+func TestFormatter_FormatComparison_Charts(t *testing.T) {
+	comparison := comparisonFixture()
+	comparison.Results[0].Download.Series = []types.TransferSample{
+		{Elapsed: 100, Rate: 6_250_000}, {Elapsed: 200, Rate: 12_500_000},
+	}
+
+	f := NewFormatter(false, false, false)
+	if output := f.FormatComparison(comparison); strings.Contains(output, "peak") {
+		t.Errorf("Expected no charts unless enabled, got:\n%s", output)
+	}
+
+	f.SetCharts(true)
+	output := f.FormatComparison(comparison)
+	want := "\nServer 101 (ipv4)\n  Download   ▅█  100.00 Mbps peak\n"
+	if !strings.Contains(output, want) {
+		t.Errorf("Expected output to contain %q, got:\n%s", want, output)
+	}
+	// The failed server has nothing to chart
+	if strings.Count(output, "peak") != 1 {
+		t.Errorf("Expected a single chart, got:\n%s", output)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/user/speed-test-go/pkg/types"
 )
@@ -13,6 +15,8 @@ type Formatter struct {
 	useBytes   bool
 	useJSON    bool
 	useVerbose bool
+	charts     bool
+	unicode    bool
 }
 
 // NewFormatter creates a new formatter
@@ -21,9 +25,22 @@ func NewFormatter(useBytes, useJSON, useVerbose bool) *Formatter {
 		useBytes:   useBytes,
 		useJSON:    useJSON,
 		useVerbose: useVerbose,
+		unicode:    true,
 	}
 }
 
+// SetCharts adds throughput sparklines and a latency histogram, drawn from
+// recorded samples, to the human-readable output
+func (f *Formatter) SetCharts(enabled bool) {
+	f.charts = enabled
+}
+
+// SetUnicode selects Unicode block characters for charts, or plain ASCII
+// for terminals without Unicode support
+func (f *Formatter) SetUnicode(enabled bool) {
+	f.unicode = enabled
+}
+
 // Format formats the test result for output
 func (f *Formatter) Format(result *types.SpeedTestResult) string {
 	if f.useJSON {
@@ -50,20 +67,275 @@ func (f *Formatter) formatHuman(result *types.SpeedTestResult) string {
 
 	// Output format matching sindresorhus/speed-test
 	sb.WriteString(fmt.Sprintf("      Ping %s\n", pingStr))
-	sb.WriteString(fmt.Sprintf("  Download %s\n", downloadStr))
-	sb.WriteString(fmt.Sprintf("    Upload %s\n", uploadStr))
+	sb.WriteString(fmt.Sprintf("  Download %s%s\n", downloadStr, formatFixedSize(result.Download)))
+	sb.WriteString(fmt.Sprintf("    Upload %s%s\n", uploadStr, formatFixedSize(result.Upload)))
+
+	// Optional charts of the recorded samples
+	if f.charts {
+		sb.WriteString(f.formatCharts(result))
+	}
+
+	// Always flag results measured without certificate verification
+	if result.Insecure {
+		sb.WriteString("\n" + insecureWarning + "\n")
+	}
+
+	// Always flag results cut short by the data cap
+	if result.Truncated {
+		sb.WriteString("\n" + formatTruncated(result.DataUsed) + "\n")
+	}
 
 	// Verbose mode - server information
 	if f.useVerbose && result.Server != nil {
 		sb.WriteString(fmt.Sprintf("\n"))
 		sb.WriteString(fmt.Sprintf("    Server   %s\n", result.Server.Host))
-		sb.WriteString(fmt.Sprintf("  Location   %s (%s)\n", result.Server.Name, result.Server.Country))
-		sb.WriteString(fmt.Sprintf("  Distance   %.1f km\n", result.Server.Distance))
+		if result.Server.Country != "" {
+			sb.WriteString(fmt.Sprintf("  Location   %s (%s)\n", result.Server.Name, result.Server.Country))
+		} else {
+			sb.WriteString(fmt.Sprintf("  Location   %s\n", result.Server.Name))
+		}
+		// Servers without coordinates have no distance
+		if result.Server.Distance > 0 {
+			sb.WriteString(fmt.Sprintf("  Distance   %.1f km\n", result.Server.Distance))
+		}
+	}
+
+	// Verbose mode - local interface information
+	if f.useVerbose && result.Interface != nil && result.Interface.InternalIP != "" {
+		local := result.Interface.InternalIP
+		var details []string
+		if result.Interface.Name != "" {
+			details = append(details, result.Interface.Name)
+		}
+		if result.AddressFamily != "" {
+			details = append(details, result.AddressFamily)
+		}
+		if len(details) > 0 {
+			local = fmt.Sprintf("%s (%s)", local, strings.Join(details, ", "))
+		}
+		sb.WriteString(fmt.Sprintf(" Interface   %s\n", local))
+	}
+
+	// Verbose mode - how latency was measured
+	if f.useVerbose && result.Ping.Method != "" {
+		sb.WriteString(fmt.Sprintf("   Latency   %d samples via %s, %.1f ms jitter\n",
+			result.Ping.Samples, result.Ping.Method, result.Ping.Jitter))
+	}
+
+	// Verbose mode - negotiated transfer protocol
+	if f.useVerbose {
+		if protocol := transferProtocol(result); protocol != "" {
+			sb.WriteString(fmt.Sprintf("  Protocol   %s\n", protocol))
+		}
+	}
+
+	// Verbose mode - endpoints that contributed to each transfer phase
+	if f.useVerbose {
+		label := "   Sources"
+		for _, phase := range []struct {
+			name string
+			urls []types.URLStats
+		}{{"download", result.Download.URLs}, {"upload", result.Upload.URLs}} {
+			for _, u := range phase.urls {
+				sb.WriteString(fmt.Sprintf("%s   %s %s: %s\n", label, phase.name, u.URL, formatURLStats(u)))
+				label = "          "
+			}
+		}
+	}
+
+	// Verbose mode - throughput of each parallel stream
+	if f.useVerbose {
+		label := "   Streams"
+		for _, phase := range []struct {
+			name    string
+			streams []types.StreamStats
+		}{{"download", result.Download.Streams}, {"upload", result.Upload.Streams}} {
+			for _, st := range phase.streams {
+				sb.WriteString(fmt.Sprintf("%s   %s #%d: %s\n", label, phase.name, st.ID, f.formatStreamStats(st)))
+				label = "          "
+			}
+		}
+	}
+
+	// Verbose mode - data used by the transfers
+	if f.useVerbose && result.DataUsed > 0 {
+		sb.WriteString(fmt.Sprintf("      Data   %.1f MB transferred\n", float64(result.DataUsed)/(1000*1000)))
+	}
+
+	// Verbose mode - server lookup time
+	if f.useVerbose && result.DNS != nil {
+		resolver := "system resolver"
+		if result.DNS.Server != "" {
+			resolver = result.DNS.Server
+		}
+		sb.WriteString(fmt.Sprintf("       DNS   %.1f ms (%s)\n", result.DNS.Duration, resolver))
+	}
+
+	// Verbose mode - cached discovery data
+	if f.useVerbose && result.Cache != nil {
+		var parts []string
+		if result.Cache.ServerList != nil {
+			parts = append(parts, "server list "+formatCacheEntry(result.Cache.ServerList))
+		}
+		if result.Cache.Location != nil {
+			parts = append(parts, "location "+formatCacheEntry(result.Cache.Location))
+		}
+		sb.WriteString(fmt.Sprintf("     Cache   %s\n", strings.Join(parts, ", ")))
+	}
+
+	return sb.String()
+}
+
+// FormatComparison formats the results of a multi-server comparison
+func (f *Formatter) FormatComparison(comparison *types.ComparisonResult) string {
+	if f.useJSON {
+		data, err := json.MarshalIndent(comparison, "", "  ")
+		if err != nil {
+			return fmt.Sprintf(`{"error": "failed to format result: %v"}`, err)
+		}
+		return string(data)
+	}
+
+	var sb strings.Builder
+	tw := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', tabwriter.AlignRight)
+
+	fmt.Fprintln(tw, "Server\tSponsor\tFamily\tDistance\tPing\tDownload\tUpload\t")
+	for _, result := range comparison.Results {
+		id, sponsor, distance, family := "-", "-", "-", "-"
+		if result.Server != nil {
+			id = result.Server.ID
+			sponsor = result.Server.Sponsor
+			if result.Server.Distance > 0 {
+				distance = fmt.Sprintf("%.1f km", result.Server.Distance)
+			}
+		}
+		if result.AddressFamily != "" {
+			family = result.AddressFamily
+		}
+
+		if result.Error != "" {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n", id, sponsor, family, distance, "-", "-", "failed")
+			continue
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%.1f ms\t%s\t%s\t\n",
+			id,
+			sponsor,
+			family,
+			distance,
+			result.Ping.Latency,
+			formatSpeed(result.Download.Bandwidth, f.useBytes),
+			formatSpeed(result.Upload.Bandwidth, f.useBytes),
+		)
+	}
+	tw.Flush()
+
+	// Errors are listed below the table to keep columns aligned
+	wroteHeader := false
+	for _, result := range comparison.Results {
+		if result.Error == "" || result.Server == nil {
+			continue
+		}
+		if !wroteHeader {
+			sb.WriteString("\n")
+			wroteHeader = true
+		}
+		sb.WriteString(fmt.Sprintf("%s: %s\n", comparisonLabel(result), result.Error))
+	}
+
+	// Optional charts of each server's samples, which do not fit the table
+	if f.charts {
+		for _, result := range comparison.Results {
+			if result.Error != "" || result.Server == nil {
+				continue
+			}
+			if charts := f.formatCharts(result); charts != "" {
+				sb.WriteString(fmt.Sprintf("\n%s\n%s", comparisonLabel(result), strings.TrimPrefix(charts, "\n")))
+			}
+		}
+	}
+
+	for _, result := range comparison.Results {
+		if result.Insecure {
+			sb.WriteString("\n" + insecureWarning + "\n")
+			break
+		}
+	}
+
+	// The cap covers the whole run, so report the data used by all servers
+	var used int64
+	truncated := false
+	for _, result := range comparison.Results {
+		used += result.DataUsed
+		truncated = truncated || result.Truncated
+	}
+	if truncated {
+		sb.WriteString("\n" + formatTruncated(used) + "\n")
 	}
 
 	return sb.String()
 }
 
+// comparisonLabel names the server of a comparison result, with its address
+// family in dual-stack mode, e.g. "Server 101 (ipv6)"
+func comparisonLabel(result *types.SpeedTestResult) string {
+	if result.AddressFamily != "" {
+		return fmt.Sprintf("Server %s (%s)", result.Server.ID, result.AddressFamily)
+	}
+	return fmt.Sprintf("Server %s", result.Server.ID)
+}
+
+// transferProtocol describes the protocols used for download and upload
+func transferProtocol(result *types.SpeedTestResult) string {
+	down, up := result.Download.Protocol, result.Upload.Protocol
+	switch {
+	case down == up || up == "":
+		return down
+	case down == "":
+		return up
+	default:
+		return fmt.Sprintf("%s down, %s up", down, up)
+	}
+}
+
+// insecureWarning is shown whenever TLS certificates were not verified
+const insecureWarning = "Warning: TLS certificate verification was disabled (--insecure)"
+
+// formatTruncated warns that transfers stopped at the data cap after used bytes
+func formatTruncated(used int64) string {
+	return fmt.Sprintf("Warning: transfers stopped at the data cap (--max-data) after %.1f MB; results are partial", float64(used)/(1000*1000))
+}
+
+// formatCacheEntry describes the age of a cached value
+func formatCacheEntry(entry *types.CacheEntry) string {
+	age := time.Duration(entry.Age) * time.Second
+	if entry.Stale {
+		return fmt.Sprintf("%s old (stale, fetch failed)", age)
+	}
+	return fmt.Sprintf("%s old", age)
+}
+
+// formatFixedSize returns the size and duration of a fixed-size transfer,
+// e.g. " (500.0 MB in 42.5 s)", or nothing for a timed one
+func formatFixedSize(t types.TransferResult) string {
+	if t.TargetBytes == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (%.1f MB in %.1f s)", float64(t.Bytes)/(1000*1000), float64(t.Elapsed)/1000)
+}
+
+// formatURLStats summarizes the requests made to a single endpoint
+func formatURLStats(u types.URLStats) string {
+	return fmt.Sprintf("%.1f MB, %d ok, %d failed", float64(u.Bytes)/(1000*1000), u.Successes, u.Failures)
+}
+
+// formatStreamStats summarizes the throughput of a single stream
+func (f *Formatter) formatStreamStats(st types.StreamStats) string {
+	return fmt.Sprintf("%s avg, %s peak, %.1f MB in %d requests",
+		formatSpeed(st.Bandwidth, f.useBytes), formatSpeed(st.PeakBandwidth, f.useBytes),
+		float64(st.Bytes)/(1000*1000), st.Requests)
+}
+
 // formatSpeed formats a speed value in Mbps or MB/s
 func formatSpeed(bytesPerSecond int64, useBytes bool) string {
 	if useBytes {
@@ -441,3 +441,257 @@ func TestFormatter_OutputFormat(t *testing.T) {
 		t.Errorf("Expected at least 2 lines of output, got: %d", lines+1)
 	}
 }
+
+func comparisonFixture() *types.ComparisonResult {
+	return &types.ComparisonResult{
+		Timestamp: time.Now(),
+		Results: []*types.SpeedTestResult{
+			{
+				Ping:          types.PingResult{Latency: 12.3},
+				Download:      types.TransferResult{Bandwidth: 12500000}, // 100 Mbps
+				Upload:        types.TransferResult{Bandwidth: 2500000},  // 20 Mbps
+				Server:        &types.ServerInfo{ID: "101", Sponsor: "Fast ISP", Distance: 10.5},
+				AddressFamily: "ipv4",
+			},
+			{
+				Server: &types.ServerInfo{ID: "202", Sponsor: "Slow ISP", Distance: 99.9},
+				Error:  "ping test failed: no successful pings",
+			},
+		},
+	}
+}
+
+func TestFormatter_FormatComparison_Human(t *testing.T) {
+	f := NewFormatter(false, false, false)
+
+	output := f.FormatComparison(comparisonFixture())
+
+	for _, want := range []string{"Server", "Download", "Family", "ipv4", "101", "Fast ISP", "10.5 km", "12.3 ms", "100.00 Mbps", "20.00 Mbps", "202", "failed"} {
+		if !contains(output, want) {
+			t.Errorf("Expected comparison output to contain %q, got:\n%s", want, output)
+		}
+	}
+
+	if !contains(output, "Server 202: ping test failed") {
+		t.Errorf("Expected comparison output to list the failure, got:\n%s", output)
+	}
+}
+
+func TestFormatter_FormatComparison_JSON(t *testing.T) {
+	f := NewFormatter(false, true, false)
+
+	output := f.FormatComparison(comparisonFixture())
+
+	var decoded types.ComparisonResult
+	if err := json.Unmarshal([]byte(output), &decoded); err != nil {
+		t.Fatalf("Expected valid JSON, got error: %v", err)
+	}
+
+	if len(decoded.Results) != 2 {
+		t.Fatalf("Expected 2 results, got: %d", len(decoded.Results))
+	}
+	if decoded.Results[1].Error == "" {
+		t.Error("Expected second result to carry its error")
+	}
+}
+
+func TestFormatter_Format_VerboseCache(t *testing.T) {
+	f := NewFormatter(false, false, true)
+
+	result := &types.SpeedTestResult{
+		Timestamp: time.Now(),
+		Cache: &types.CacheInfo{
+			ServerList: &types.CacheEntry{Age: 300},
+			Location:   &types.CacheEntry{Age: 7200, Stale: true},
+		},
+	}
+
+	output := f.Format(result)
+
+	if !contains(output, "server list 5m0s old") {
+		t.Errorf("Expected verbose output to contain server list cache age, got:\n%s", output)
+	}
+	if !contains(output, "location 2h0m0s old (stale") {
+		t.Errorf("Expected verbose output to flag stale location cache, got:\n%s", output)
+	}
+}
+
+func TestFormatter_Format_VerboseInterface(t *testing.T) {
+	f := NewFormatter(false, false, true)
+
+	result := &types.SpeedTestResult{
+		Timestamp: time.Now(),
+		Interface: &types.InterfaceInfo{
+			InternalIP: "192.168.1.20",
+			Name:       "eth1",
+		},
+	}
+
+	output := f.Format(result)
+
+	if !contains(output, "192.168.1.20 (eth1)") {
+		t.Errorf("Expected verbose output to contain interface, got:\n%s", output)
+	}
+}
+
+func TestFormatter_Format_VerboseDNS(t *testing.T) {
+	f := NewFormatter(false, false, true)
+
+	result := &types.SpeedTestResult{
+		Timestamp: time.Now(),
+		DNS: &types.DNSResult{
+			Host:     "speedtest.example.com",
+			Server:   "1.1.1.1:53",
+			Duration: 12.34,
+		},
+	}
+
+	output := f.Format(result)
+	if !contains(output, "12.3 ms (1.1.1.1:53)") {
+		t.Errorf("Expected verbose output to contain DNS time, got:\n%s", output)
+	}
+
+	result.DNS.Server = ""
+	output = f.Format(result)
+	if !contains(output, "(system resolver)") {
+		t.Errorf("Expected verbose output to name the system resolver, got:\n%s", output)
+	}
+}
+
+func TestFormatter_Format_Insecure(t *testing.T) {
+	f := NewFormatter(false, false, false)
+
+	output := f.Format(&types.SpeedTestResult{Timestamp: time.Now(), Insecure: true})
+	if !contains(output, "TLS certificate verification was disabled") {
+		t.Errorf("Expected insecure results to be flagged, got:\n%s", output)
+	}
+
+	output = f.Format(&types.SpeedTestResult{Timestamp: time.Now()})
+	if contains(output, "TLS certificate verification") {
+		t.Errorf("Expected no warning for verified results, got:\n%s", output)
+	}
+}
+
+func TestFormatter_Format_VerboseProtocol(t *testing.T) {
+	f := NewFormatter(false, false, true)
+
+	result := &types.SpeedTestResult{
+		Timestamp: time.Now(),
+		Download:  types.TransferResult{Protocol: "HTTP/2.0"},
+		Upload:    types.TransferResult{Protocol: "HTTP/2.0"},
+	}
+	if output := f.Format(result); !contains(output, "Protocol   HTTP/2.0\n") {
+		t.Errorf("Expected verbose output to contain protocol, got:\n%s", output)
+	}
+
+	result.Upload.Protocol = "HTTP/1.1"
+	if output := f.Format(result); !contains(output, "HTTP/2.0 down, HTTP/1.1 up") {
+		t.Errorf("Expected verbose output to list both protocols, got:\n%s", output)
+	}
+}
+
+func TestFormatter_Format_VerboseLatency(t *testing.T) {
+	f := NewFormatter(false, false, true)
+
+	result := &types.SpeedTestResult{
+		Timestamp: time.Now(),
+		Ping:      types.PingResult{Latency: 12.5, Jitter: 0.42, Method: "websocket", Samples: 20},
+	}
+	if output := f.Format(result); !contains(output, "20 samples via websocket, 0.4 ms jitter") {
+		t.Errorf("Expected verbose output to describe the latency probe, got:\n%s", output)
+	}
+}
+
+func TestFormatter_Format_VerboseSources(t *testing.T) {
+	f := NewFormatter(false, false, true)
+
+	result := &types.SpeedTestResult{
+		Timestamp: time.Now(),
+		Download: types.TransferResult{URLs: []types.URLStats{
+			{URL: "http://a/speedtest/random1000x1000.jpg", Bytes: 12_500_000, Successes: 5},
+			{URL: "https://dl.example.com/1mb.bin", Bytes: 1_000_000, Successes: 1, Failures: 2},
+		}},
+		Upload: types.TransferResult{URLs: []types.URLStats{
+			{URL: "http://a/speedtest/upload.php", Bytes: 2_000_000, Successes: 2},
+		}},
+	}
+
+	output := f.Format(result)
+	for _, want := range []string{
+		"   Sources   download http://a/speedtest/random1000x1000.jpg: 12.5 MB, 5 ok, 0 failed\n",
+		"             download https://dl.example.com/1mb.bin: 1.0 MB, 1 ok, 2 failed\n",
+		"             upload http://a/speedtest/upload.php: 2.0 MB, 2 ok, 0 failed\n",
+	} {
+		if !contains(output, want) {
+			t.Errorf("Expected verbose output to contain %q, got:\n%s", want, output)
+		}
+	}
+}
+
+func TestFormatter_Format_VerboseStreams(t *testing.T) {
+	f := NewFormatter(false, false, true)
+
+	result := &types.SpeedTestResult{
+		Timestamp: time.Now(),
+		Download: types.TransferResult{Streams: []types.StreamStats{
+			{ID: 1, Bytes: 25_000_000, Bandwidth: 2_500_000, PeakBandwidth: 3_125_000, Requests: 12},
+			{ID: 2, Bytes: 5_000_000, Bandwidth: 500_000, PeakBandwidth: 625_000, Requests: 3},
+		}},
+	}
+
+	output := f.Format(result)
+	for _, want := range []string{
+		"   Streams   download #1: 20.00 Mbps avg, 25.00 Mbps peak, 25.0 MB in 12 requests\n",
+		"             download #2: 4.00 Mbps avg, 5.00 Mbps peak, 5.0 MB in 3 requests\n",
+	} {
+		if !contains(output, want) {
+			t.Errorf("Expected verbose output to contain %q, got:\n%s", want, output)
+		}
+	}
+}
+
+func TestFormatter_Format_FixedSize(t *testing.T) {
+	f := NewFormatter(false, false, false)
+
+	result := &types.SpeedTestResult{
+		Timestamp: time.Now(),
+		Download:  types.TransferResult{Bandwidth: 12_500_000, Bytes: 500_000_000, Elapsed: 40_000, TargetBytes: 500_000_000},
+		Upload:    types.TransferResult{Bandwidth: 1_250_000, Bytes: 12_500_000, Elapsed: 10_000},
+	}
+
+	output := f.Format(result)
+	if !contains(output, "  Download 100.00 Mbps (500.0 MB in 40.0 s)\n") {
+		t.Errorf("Expected the size and time of the fixed-size download, got:\n%s", output)
+	}
+	if !contains(output, "    Upload 10.00 Mbps\n") {
+		t.Errorf("Expected a plain line for the timed upload, got:\n%s", output)
+	}
+}
+
+func TestFormatter_Format_Truncated(t *testing.T) {
+	result := &types.SpeedTestResult{
+		Timestamp: time.Now(),
+		Download:  types.TransferResult{Bandwidth: 12_500_000, Bytes: 200_000_000},
+		DataUsed:  200_000_000,
+		Truncated: true,
+	}
+
+	output := NewFormatter(false, false, false).Format(result)
+	if !contains(output, "stopped at the data cap (--max-data) after 200.0 MB") {
+		t.Errorf("Expected a truncation warning, got:\n%s", output)
+	}
+
+	output = NewFormatter(false, false, true).Format(result)
+	if !contains(output, "      Data   200.0 MB transferred\n") {
+		t.Errorf("Expected the data used in verbose output, got:\n%s", output)
+	}
+
+	comparison := &types.ComparisonResult{Results: []*types.SpeedTestResult{
+		{Server: &types.ServerInfo{ID: "1"}, DataUsed: 150_000_000},
+		{Server: &types.ServerInfo{ID: "2"}, DataUsed: 50_000_000, Truncated: true},
+	}}
+	output = NewFormatter(false, false, false).FormatComparison(comparison)
+	if !contains(output, "after 200.0 MB") {
+		t.Errorf("Expected the data used by all servers, got:\n%s", output)
+	}
+}
@@ -2,59 +2,89 @@ package output
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/user/speed-test-go/internal/transfer"
 	"github.com/user/speed-test-go/pkg/types"
 )
 
-// ProgressReporter handles real-time progress display
+// progressBarWidth is the number of cells in a progress bar
+const progressBarWidth = 20
+
+// ProgressReporter handles real-time progress display. Reports may come from
+// the goroutines measuring a phase, so all methods are safe for concurrent use.
 type ProgressReporter struct {
+	mu         sync.Mutex
 	formatter  *Formatter
+	out        io.Writer
 	lastOutput string
 	updateFreq time.Duration
 	state      types.OutputState
+	lines      map[types.OutputState]string // last report of each phase
 }
 
-// NewProgressReporter creates a new progress reporter
+// NewProgressReporter creates a new progress reporter writing to stderr, so
+// progress never mixes with results on stdout
 func NewProgressReporter(formatter *Formatter) *ProgressReporter {
 	return &ProgressReporter{
 		formatter:  formatter,
+		out:        os.Stderr,
 		updateFreq: 50 * time.Millisecond,
 		state:      types.StateIdle,
+		lines:      make(map[types.OutputState]string),
 	}
 }
 
 // SetState updates the current test state
 func (pr *ProgressReporter) SetState(state types.OutputState) {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
 	pr.state = state
 }
 
 // ReportPingProgress reports ping test progress
 func (pr *ProgressReporter) ReportPingProgress(latency float64) {
-	output := pr.formatProgressOutput(func() string {
-		return fmt.Sprintf("      Ping %.1f ms", latency)
-	})
-	pr.printOutput(output)
+	pr.report(fmt.Sprintf("%.1f ms", latency))
 }
 
 // ReportDownloadProgress reports download test progress
 func (pr *ProgressReporter) ReportDownloadProgress(progress transfer.ProgressInfo) {
-	output := pr.formatProgressOutput(func() string {
-		rateStr := formatTransferSpeed(progress.Rate, pr.formatter.useBytes)
-		return fmt.Sprintf("  Download %s", rateStr)
-	})
-	pr.printOutput(output)
+	pr.report(pr.formatTransferProgress(progress))
 }
 
 // ReportUploadProgress reports upload test progress
 func (pr *ProgressReporter) ReportUploadProgress(progress transfer.ProgressInfo) {
-	output := pr.formatProgressOutput(func() string {
-		rateStr := formatTransferSpeed(progress.Rate, pr.formatter.useBytes)
-		return fmt.Sprintf("    Upload %s", rateStr)
-	})
-	pr.printOutput(output)
+	pr.report(pr.formatTransferProgress(progress))
+}
+
+// Clear erases the progress display, e.g. before printing the results
+func (pr *ProgressReporter) Clear() {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	if pr.lastOutput == "" {
+		return
+	}
+	fmt.Fprint(pr.out, cursorUp(pr.lastOutput)+"\r\033[J")
+	pr.lastOutput = ""
+	clear(pr.lines)
+}
+
+// report shows line for the current state
+func (pr *ProgressReporter) report(line string) {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	pr.lines[pr.state] = line
+	pr.printOutput(pr.formatProgressOutput(func() string { return line }))
+}
+
+// formatTransferProgress formats the rate followed by a progress bar
+func (pr *ProgressReporter) formatTransferProgress(progress transfer.ProgressInfo) string {
+	rateStr := formatTransferSpeed(progress.Rate, pr.formatter.useBytes)
+	return fmt.Sprintf("%-12s %s", rateStr, formatProgressBar(progress))
 }
 
 func (pr *ProgressReporter) formatProgressOutput(activeLine func() string) string {
@@ -68,7 +98,8 @@ func (pr *ProgressReporter) formatProgressOutput(activeLine func() string) strin
 	}
 
 	for _, state := range states {
-		line := "  "
+		// Finished phases keep their last report
+		line := pr.lines[state]
 		if state == pr.state {
 			line = activeLine()
 		}
@@ -86,13 +117,39 @@ func (pr *ProgressReporter) formatProgressOutput(activeLine func() string) strin
 	return sb.String()
 }
 
+// printOutput redraws the display over the previous output
 func (pr *ProgressReporter) printOutput(output string) {
-	// Clear previous output and print new
-	// In a real implementation, use ANSI escape codes
-	fmt.Print("\r" + output)
+	redraw := cursorUp(pr.lastOutput) + "\r" + strings.ReplaceAll(output, "\n", "\033[K\n")
+	fmt.Fprint(pr.out, redraw)
 	pr.lastOutput = output
 }
 
+// cursorUp returns the ANSI escape code moving the cursor back to the first
+// line of output
+func cursorUp(output string) string {
+	if n := strings.Count(output, "\n"); n > 0 {
+		return fmt.Sprintf("\033[%dA", n)
+	}
+	return ""
+}
+
+// formatProgressBar renders the progress fraction as a bar with the
+// percentage and, while the phase runs, its ETA, e.g.
+// "[#########-----------]  45% ETA 8s"
+func formatProgressBar(progress transfer.ProgressInfo) string {
+	fraction := min(max(progress.Progress, 0), 1)
+	filled := int(fraction * progressBarWidth)
+
+	bar := fmt.Sprintf("[%s%s] %3.0f%%",
+		strings.Repeat("#", filled),
+		strings.Repeat("-", progressBarWidth-filled),
+		fraction*100)
+	if fraction < 1 && progress.ETA > 0 {
+		bar += " ETA " + progress.ETA.Round(time.Second).String()
+	}
+	return bar
+}
+
 // Spinner provides a simple spinner animation
 type Spinner struct {
 	frames   []string
@@ -1,6 +1,7 @@
 package output
 
 import (
+	"bytes"
 	"strings"
 	"testing"
 	"time"
@@ -438,3 +439,50 @@ func TestProgressReporter_MultipleProgressReports(t *testing.T) {
 		pr.ReportDownloadProgress(progress)
 	}
 }
+
+func TestFormatProgressBar(t *testing.T) {
+	tests := []struct {
+		progress transfer.ProgressInfo
+		want     string
+	}{
+		{transfer.ProgressInfo{}, "[--------------------]   0%"},
+		{transfer.ProgressInfo{Progress: 0.45, ETA: 8200 * time.Millisecond}, "[#########-----------]  45% ETA 8s"},
+		{transfer.ProgressInfo{Progress: 0.5, ETA: 75 * time.Second}, "[##########----------]  50% ETA 1m15s"},
+		{transfer.ProgressInfo{Progress: 1}, "[####################] 100%"},
+		{transfer.ProgressInfo{Progress: 1.5}, "[####################] 100%"},
+	}
+
+	for _, tt := range tests {
+		if got := formatProgressBar(tt.progress); got != tt.want {
+			t.Errorf("formatProgressBar(%+v) = %q, want %q", tt.progress, got, tt.want)
+		}
+	}
+}
+
+func TestProgressReporter_KeepsFinishedPhases(t *testing.T) {
+	var out bytes.Buffer
+	pr := NewProgressReporter(NewFormatter(false, false, false))
+	pr.out = &out
+
+	pr.SetState(types.StateDownload)
+	pr.ReportDownloadProgress(transfer.ProgressInfo{Rate: 1250000, Progress: 1})
+	pr.SetState(types.StateUpload)
+	pr.ReportUploadProgress(transfer.ProgressInfo{Rate: 625000, Progress: 0.5, ETA: 10 * time.Second})
+
+	if !strings.Contains(pr.lastOutput, "Download 10.00 Mbps") || !strings.Contains(pr.lastOutput, "100%") {
+		t.Errorf("Expected the finished download line, got:\n%s", pr.lastOutput)
+	}
+	if !strings.Contains(pr.lastOutput, "Upload 5.00 Mbps") || !strings.Contains(pr.lastOutput, "ETA 10s") {
+		t.Errorf("Expected the upload line with its ETA, got:\n%s", pr.lastOutput)
+	}
+
+	// Redraws move the cursor back over the previous lines
+	if !strings.Contains(out.String(), "\033[3A") {
+		t.Errorf("Expected the second report to redraw the first, got: %q", out.String())
+	}
+
+	pr.Clear()
+	if pr.lastOutput != "" {
+		t.Errorf("Expected Clear to reset the display, got: %q", pr.lastOutput)
+	}
+}
@@ -4,17 +4,24 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
+	"os"
 	"strings"
 	"time"
 
+	"github.com/user/speed-test-go/internal/network"
 	"github.com/user/speed-test-go/pkg/types"
 )
 
 const serverListURL = "https://www.speedtest.net/api/js/servers?engine=js&limit=10"
 
-// FetchServerList retrieves the list of available speed test servers
-func FetchServerList(ctx context.Context) ([]*types.Server, error) {
+// DiscoveryTimeout bounds the request fetching the server list
+const DiscoveryTimeout = 15 * time.Second
+
+// FetchServerList retrieves the list of available speed test servers.
+// A nil client uses the default network factory.
+func FetchServerList(ctx context.Context, client *http.Client) ([]*types.Server, error) {
 	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
@@ -23,11 +30,10 @@ func FetchServerList(ctx context.Context) ([]*types.Server, error) {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
 	req.Header.Set("Accept", "application/json")
 
-	client := &http.Client{
-		Timeout: 15 * time.Second,
+	if client == nil {
+		client = network.DefaultFactory().Client(DiscoveryTimeout)
 	}
 
 	resp, err := client.Do(req)
@@ -48,6 +54,32 @@ func FetchServerList(ctx context.Context) ([]*types.Server, error) {
 	return servers, nil
 }
 
+// LoadServerFile reads a list of servers from a JSON file using the same
+// shape as the speedtest.net server list API
+func LoadServerFile(path string) ([]*types.Server, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read server file: %w", err)
+	}
+
+	var servers []*types.Server
+	if err := json.Unmarshal(data, &servers); err != nil {
+		return nil, fmt.Errorf("failed to decode server file: %w", err)
+	}
+
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("server file %s contains no servers", path)
+	}
+
+	for i, s := range servers {
+		if s == nil || s.URL == "" {
+			return nil, fmt.Errorf("server %d in %s has no url", i, path)
+		}
+	}
+
+	return servers, nil
+}
+
 // GetServerHost extracts the host from a server URL (for display purposes)
 func GetServerHost(server *types.Server) string {
 	url := server.URL
@@ -67,6 +99,21 @@ func GetServerHost(server *types.Server) string {
 	return url
 }
 
+// tcpPort is the port of the speedtest.net TCP protocol
+const tcpPort = "8080"
+
+// GetServerTCPAddr returns the host:port of the server's TCP protocol endpoint
+func GetServerTCPAddr(server *types.Server) string {
+	host := server.Host
+	if host == "" {
+		host = GetServerHost(server)
+	}
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, tcpPort)
+	}
+	return host
+}
+
 // GetServerBaseURL returns the base URL for HTTP requests (with protocol)
 func GetServerBaseURL(server *types.Server) string {
 	url := server.URL
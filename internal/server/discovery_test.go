@@ -4,6 +4,8 @@ import (
 	"context"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -241,3 +243,80 @@ func TestGetServerHost_QueryString(t *testing.T) {
 		t.Errorf("Expected 'example.com', got: %s", host)
 	}
 }
+
+func TestLoadServerFile(t *testing.T) {
+	t.Run("valid file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "servers.json")
+		data := `[
+  {"url": "http://st1.internal:8080/speedtest/upload.php", "lat": "52.52", "lon": "13.40", "name": "Berlin", "cc": "DE", "sponsor": "Internal", "id": "1", "host": "st1.internal:8080"},
+  {"url": "http://st2.internal:8080/speedtest/upload.php", "lat": "48.13", "lon": "11.58", "name": "Munich", "cc": "DE", "sponsor": "Internal", "id": "2", "host": "st2.internal:8080"}
+]`
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		servers, err := LoadServerFile(path)
+		if err != nil {
+			t.Fatalf("LoadServerFile() unexpected error: %v", err)
+		}
+		if len(servers) != 2 {
+			t.Fatalf("Expected 2 servers, got: %d", len(servers))
+		}
+		if servers[0].ID != "1" || servers[0].Lat != "52.52" || servers[1].Host != "st2.internal:8080" {
+			t.Errorf("Unexpected servers: %+v, %+v", servers[0], servers[1])
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		if _, err := LoadServerFile(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+			t.Error("Expected error for missing file")
+		}
+	})
+
+	t.Run("invalid JSON", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "servers.json")
+		if err := os.WriteFile(path, []byte("<servers/>"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadServerFile(path); err == nil {
+			t.Error("Expected error for invalid JSON")
+		}
+	})
+
+	t.Run("empty list", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "servers.json")
+		if err := os.WriteFile(path, []byte("[]"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadServerFile(path); err == nil {
+			t.Error("Expected error for empty server list")
+		}
+	})
+
+	t.Run("server without url", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "servers.json")
+		if err := os.WriteFile(path, []byte(`[{"id": "1"}]`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadServerFile(path); err == nil {
+			t.Error("Expected error for server without url")
+		}
+	})
+}
+
+func TestGetServerTCPAddr(t *testing.T) {
+	tests := []struct {
+		server *types.Server
+		want   string
+	}{
+		{&types.Server{Host: "speedtest.example.com:8080", URL: "https://speedtest.example.com/speedtest/upload.php"}, "speedtest.example.com:8080"},
+		{&types.Server{URL: "http://speedtest.example.com:5060/speedtest/upload.php"}, "speedtest.example.com:5060"},
+		{&types.Server{URL: "http://speedtest.example.com/speedtest/upload.php"}, "speedtest.example.com:8080"},
+	}
+
+	for _, tt := range tests {
+		if got := GetServerTCPAddr(tt.server); got != tt.want {
+			t.Errorf("GetServerTCPAddr(%+v) = %s, want %s", tt.server, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"path"
+	"strings"
+
+	"github.com/user/speed-test-go/pkg/types"
+)
+
+// Filter describes which servers are eligible for selection.
+// Empty fields place no restriction on the server list.
+type Filter struct {
+	Countries        []string // country codes (Server.CC) to include
+	ExcludeCountries []string // country codes (Server.CC) to exclude
+	Sponsors         []string // sponsor substrings to include
+	ExcludeSponsors  []string // sponsor substrings to exclude
+	HostPattern      string   // glob pattern matched against the server host
+	MaxDistance      float64  // maximum distance in km, 0 for no limit
+	ExcludeIDs       []string // server IDs to exclude
+}
+
+// IsEmpty reports whether the filter places no restriction on servers
+func (f Filter) IsEmpty() bool {
+	return len(f.Countries) == 0 &&
+		len(f.ExcludeCountries) == 0 &&
+		len(f.Sponsors) == 0 &&
+		len(f.ExcludeSponsors) == 0 &&
+		f.HostPattern == "" &&
+		f.MaxDistance <= 0 &&
+		len(f.ExcludeIDs) == 0
+}
+
+// Match reports whether a server satisfies every condition of the filter.
+// Distances must already be calculated for MaxDistance to take effect.
+func (f Filter) Match(server *types.Server) bool {
+	if server == nil {
+		return false
+	}
+
+	if len(f.Countries) > 0 && !containsFold(f.Countries, server.CC) {
+		return false
+	}
+	if containsFold(f.ExcludeCountries, server.CC) {
+		return false
+	}
+
+	if len(f.Sponsors) > 0 && !containsSubstringFold(f.Sponsors, server.Sponsor) {
+		return false
+	}
+	if containsSubstringFold(f.ExcludeSponsors, server.Sponsor) {
+		return false
+	}
+
+	if f.HostPattern != "" {
+		host := server.Host
+		if host == "" {
+			host = GetServerHost(server)
+		}
+		matched, err := path.Match(strings.ToLower(f.HostPattern), strings.ToLower(host))
+		if err != nil || !matched {
+			return false
+		}
+	}
+
+	if f.MaxDistance > 0 && server.Distance > f.MaxDistance {
+		return false
+	}
+
+	for _, id := range f.ExcludeIDs {
+		if server.ID == id {
+			return false
+		}
+	}
+
+	return true
+}
+
+// FilterServers returns the servers that match the filter, preserving order
+func FilterServers(servers []*types.Server, filter Filter) []*types.Server {
+	if filter.IsEmpty() {
+		return servers
+	}
+
+	filtered := make([]*types.Server, 0, len(servers))
+	for _, s := range servers {
+		if filter.Match(s) {
+			filtered = append(filtered, s)
+		}
+	}
+	return filtered
+}
+
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsSubstringFold(values []string, s string) bool {
+	s = strings.ToLower(s)
+	for _, v := range values {
+		if v != "" && strings.Contains(s, strings.ToLower(v)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/user/speed-test-go/pkg/types"
+)
+
+func filterTestServers() []*types.Server {
+	return []*types.Server{
+		{ID: "1", CC: "US", Sponsor: "Comcast Cable", Host: "speed.comcast.net:8080", Distance: 10},
+		{ID: "2", CC: "DE", Sponsor: "Deutsche Telekom", Host: "speedtest.telekom.de:8080", Distance: 500},
+		{ID: "3", CC: "US", Sponsor: "Bad Hosting LLC", Host: "st.badhost.com:8080", Distance: 50},
+		{ID: "4", CC: "ID", Sponsor: "Telkom Indonesia", Host: "speedtest.telkom.co.id:8080", Distance: 2000},
+	}
+}
+
+func filterIDs(servers []*types.Server) []string {
+	ids := make([]string, 0, len(servers))
+	for _, s := range servers {
+		ids = append(ids, s.ID)
+	}
+	return ids
+}
+
+func TestFilterServers(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{name: "empty filter", filter: Filter{}, want: []string{"1", "2", "3", "4"}},
+		{name: "include country", filter: Filter{Countries: []string{"us"}}, want: []string{"1", "3"}},
+		{name: "exclude country", filter: Filter{ExcludeCountries: []string{"US"}}, want: []string{"2", "4"}},
+		{name: "include sponsor", filter: Filter{Sponsors: []string{"telkom"}}, want: []string{"4"}},
+		{name: "exclude sponsor", filter: Filter{ExcludeSponsors: []string{"bad hosting", "telekom"}}, want: []string{"1", "4"}},
+		{name: "host pattern", filter: Filter{HostPattern: "speedtest.*"}, want: []string{"2", "4"}},
+		{name: "max distance", filter: Filter{MaxDistance: 100}, want: []string{"1", "3"}},
+		{name: "exclude IDs", filter: Filter{ExcludeIDs: []string{"1", "4"}}, want: []string{"2", "3"}},
+		{
+			name:   "combined",
+			filter: Filter{Countries: []string{"US"}, ExcludeSponsors: []string{"Bad"}, MaxDistance: 100},
+			want:   []string{"1"},
+		},
+		{name: "no match", filter: Filter{Countries: []string{"FR"}}, want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := filterIDs(FilterServers(filterTestServers(), tt.filter))
+			if len(got) != len(tt.want) {
+				t.Fatalf("FilterServers() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("FilterServers() = %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestFilter_HostPatternFallsBackToURL(t *testing.T) {
+	f := Filter{HostPattern: "*.example.com"}
+	srv := &types.Server{ID: "1", URL: "http://st1.example.com/speedtest/upload.php"}
+
+	if !f.Match(srv) {
+		t.Error("Expected host pattern to match host derived from URL")
+	}
+}
+
+func TestFilter_MatchNil(t *testing.T) {
+	if (Filter{}).Match(nil) {
+		t.Error("Expected nil server not to match")
+	}
+}
+
+func TestFilter_IsEmpty(t *testing.T) {
+	if !(Filter{}).IsEmpty() {
+		t.Error("Expected zero Filter to be empty")
+	}
+	if (Filter{MaxDistance: 1}).IsEmpty() {
+		t.Error("Expected Filter with MaxDistance to be non-empty")
+	}
+}
@@ -7,11 +7,13 @@ import (
 	"sync"
 	"time"
 
+	"github.com/user/speed-test-go/internal/network"
 	"github.com/user/speed-test-go/pkg/types"
 )
 
 const (
-	defaultPingTimeout = 5 * time.Second
+	// PingTimeout bounds each latency request made during server selection
+	PingTimeout        = 5 * time.Second
 	maxConcurrentPings = 3
 )
 
@@ -34,8 +36,9 @@ func FindServerByID(servers []*types.Server, id string) *types.Server {
 
 // SelectBestServerByPing selects the best server by pinging the top N closest servers
 // and choosing the one with the lowest latency. If numServers is 0 or greater than
-// available servers, it will ping all available servers.
-func SelectBestServerByPing(ctx context.Context, servers []*types.Server, numServers int) (*types.Server, error) {
+// available servers, it will ping all available servers. A nil client uses the
+// default network factory.
+func SelectBestServerByPing(ctx context.Context, client *http.Client, servers []*types.Server, numServers int) (*types.Server, error) {
 	if len(servers) == 0 {
 		return nil, fmt.Errorf("no servers available")
 	}
@@ -49,7 +52,7 @@ func SelectBestServerByPing(ctx context.Context, servers []*types.Server, numSer
 	serversToTest := servers[:numServers]
 
 	// Ping all servers concurrently
-	latencies := pingServers(ctx, serversToTest)
+	latencies := pingServers(ctx, pingClient(client), serversToTest)
 
 	if len(latencies) == 0 {
 		// If all pings failed, return the closest server
@@ -68,12 +71,16 @@ func SelectBestServerByPing(ctx context.Context, servers []*types.Server, numSer
 	return best.Server, nil
 }
 
-// pingServers pings multiple servers concurrently and returns their latencies
-func pingServers(ctx context.Context, servers []*types.Server) []ServerLatency {
-	client := &http.Client{
-		Timeout: defaultPingTimeout,
+// pingClient returns client, or a default client with the ping timeout if nil
+func pingClient(client *http.Client) *http.Client {
+	if client == nil {
+		return network.DefaultFactory().Client(PingTimeout)
 	}
+	return client
+}
 
+// pingServers pings multiple servers concurrently and returns their latencies
+func pingServers(ctx context.Context, client *http.Client, servers []*types.Server) []ServerLatency {
 	var mu sync.Mutex
 	var results []ServerLatency
 	var wg sync.WaitGroup
@@ -126,14 +133,13 @@ func measureServerLatency(ctx context.Context, client *http.Client, server *type
 
 		start := time.Now()
 
-		ctx, cancel := context.WithTimeout(ctx, defaultPingTimeout)
+		ctx, cancel := context.WithTimeout(ctx, PingTimeout)
 		defer cancel()
 
 		req, err := http.NewRequestWithContext(ctx, "GET", latencyURL, nil)
 		if err != nil {
 			continue
 		}
-		req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
 
 		resp, err := client.Do(req)
 		if err != nil {
@@ -159,17 +165,14 @@ func measureServerLatency(ctx context.Context, client *http.Client, server *type
 	return sum / time.Duration(len(latencies))
 }
 
-// GetLatencyResult returns a PingResult for a single server
-func GetLatencyResult(ctx context.Context, server *types.Server) (*types.PingResult, error) {
+// GetLatencyResult returns a PingResult for a single server.
+// A nil client uses the default network factory.
+func GetLatencyResult(ctx context.Context, client *http.Client, server *types.Server) (*types.PingResult, error) {
 	if server == nil {
 		return nil, fmt.Errorf("server is nil")
 	}
 
-	client := &http.Client{
-		Timeout: defaultPingTimeout,
-	}
-
-	latency := measureServerLatency(ctx, client, server)
+	latency := measureServerLatency(ctx, pingClient(client), server)
 	if latency == 0 {
 		return nil, fmt.Errorf("failed to measure latency to server %s", server.ID)
 	}
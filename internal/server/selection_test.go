@@ -71,7 +71,7 @@ func TestFindServerByID(t *testing.T) {
 
 func TestSelectBestServerByPing(t *testing.T) {
 	t.Run("empty server list", func(t *testing.T) {
-		_, err := SelectBestServerByPing(context.Background(), []*types.Server{}, 5)
+		_, err := SelectBestServerByPing(context.Background(), nil, []*types.Server{}, 5)
 		if err == nil {
 			t.Error("SelectBestServerByPing() expected error for empty list")
 		}
@@ -92,7 +92,7 @@ func TestSelectBestServerByPing(t *testing.T) {
 
 		servers[0].URL = ts.URL + "/speedtest/latency.txt"
 
-		result, err := SelectBestServerByPing(context.Background(), servers, 1)
+		result, err := SelectBestServerByPing(context.Background(), nil, servers, 1)
 		if err != nil {
 			t.Errorf("SelectBestServerByPing() unexpected error: %v", err)
 		}
@@ -123,7 +123,7 @@ func TestSelectBestServerByPing(t *testing.T) {
 			s.URL = ts.URL + "/speedtest/latency.txt"
 		}
 
-		result, err := SelectBestServerByPing(context.Background(), servers, 3)
+		result, err := SelectBestServerByPing(context.Background(), nil, servers, 3)
 		if err != nil {
 			t.Errorf("SelectBestServerByPing() unexpected error: %v", err)
 		}
@@ -151,7 +151,7 @@ func TestSelectBestServerByPing(t *testing.T) {
 		}
 
 		// Only test 2 servers
-		result, err := SelectBestServerByPing(context.Background(), servers, 2)
+		result, err := SelectBestServerByPing(context.Background(), nil, servers, 2)
 		if err != nil {
 			t.Errorf("SelectBestServerByPing() unexpected error: %v", err)
 		}
@@ -178,7 +178,7 @@ func TestSelectBestServerByPing(t *testing.T) {
 		}
 
 		// Zero should test all servers
-		result, err := SelectBestServerByPing(context.Background(), servers, 0)
+		result, err := SelectBestServerByPing(context.Background(), nil, servers, 0)
 		if err != nil {
 			t.Errorf("SelectBestServerByPing() unexpected error: %v", err)
 		}
@@ -190,7 +190,7 @@ func TestSelectBestServerByPing(t *testing.T) {
 
 func TestGetLatencyResult(t *testing.T) {
 	t.Run("nil server", func(t *testing.T) {
-		_, err := GetLatencyResult(context.Background(), nil)
+		_, err := GetLatencyResult(context.Background(), nil, nil)
 		if err == nil {
 			t.Error("GetLatencyResult() expected error for nil server")
 		}
@@ -209,7 +209,7 @@ func TestGetLatencyResult(t *testing.T) {
 			URL: server.URL + "/speedtest/latency.txt",
 		}
 
-		result, err := GetLatencyResult(context.Background(), srv)
+		result, err := GetLatencyResult(context.Background(), nil, srv)
 		if err != nil {
 			t.Logf("GetLatencyResult() error: %v (may be network issue)", err)
 		}
@@ -237,7 +237,7 @@ func TestGetLatencyResult(t *testing.T) {
 		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
 		defer cancel()
 
-		_, err := GetLatencyResult(ctx, srv)
+		_, err := GetLatencyResult(ctx, nil, srv)
 		if err == nil {
 			t.Error("GetLatencyResult() expected error for timeout")
 		}
@@ -0,0 +1,73 @@
+package test
+
+import (
+	"context"
+
+	"github.com/user/speed-test-go/internal/network"
+	"github.com/user/speed-test-go/internal/transfer"
+	"github.com/user/speed-test-go/pkg/types"
+)
+
+// Backend measures a kind of speed test service. Every method makes its
+// connections through the given factory, so the runner can apply interface
+// binding, address family and TLS settings to any backend.
+type Backend interface {
+	// Name identifies the backend, e.g. "speedtest"
+	Name() string
+
+	// Servers returns the candidate servers. The runner calculates distances
+	// from the servers' coordinates and sorts them.
+	Servers(ctx context.Context, factory *network.Factory) ([]*types.Server, error)
+
+	// Ping measures latency and jitter to srv
+	Ping(ctx context.Context, factory *network.Factory, srv *types.Server) (*types.PingResult, error)
+
+	// Download measures download throughput from srv
+	Download(ctx context.Context, factory *network.Factory, srv *types.Server) (*types.TransferResult, error)
+
+	// Upload measures upload throughput to srv
+	Upload(ctx context.Context, factory *network.Factory, srv *types.Server) (*types.TransferResult, error)
+}
+
+// ServerSelector is implemented by backends that can pick the best of
+// several candidate servers. Without it the runner uses the closest server.
+type ServerSelector interface {
+	SelectServer(ctx context.Context, factory *network.Factory, candidates []*types.Server, n int) (*types.Server, error)
+}
+
+// EndpointBackend is implemented by backends measuring a configurable
+// endpoint rather than a fixed service, so each endpoint's server list is
+// cached separately
+type EndpointBackend interface {
+	// Endpoint returns the base URL being measured
+	Endpoint() string
+}
+
+// SampleRecorder is implemented by backends that can record time series of
+// their measurements. The runner enables it when samples are requested.
+type SampleRecorder interface {
+	SetSamples(enabled bool)
+}
+
+// TransferSizer is implemented by backends that can transfer a fixed number
+// of bytes instead of measuring for a fixed duration
+type TransferSizer interface {
+	// SetTransferBytes sets the bytes to download and upload; 0 keeps a
+	// phase timed
+	SetTransferBytes(download, upload int64)
+}
+
+// DataLimiter is implemented by backends that can stop their transfers once
+// a data cap shared by every phase of a run has been used up
+type DataLimiter interface {
+	SetDataCap(limit *transfer.DataCap)
+}
+
+// ProgressFunc receives the progress of the phase being measured
+type ProgressFunc func(state types.OutputState, info transfer.ProgressInfo)
+
+// ProgressNotifier is implemented by backends that report the progress of
+// their transfers while they run
+type ProgressNotifier interface {
+	SetProgress(fn ProgressFunc)
+}
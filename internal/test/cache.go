@@ -0,0 +1,108 @@
+package test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/user/speed-test-go/internal/cache"
+	"github.com/user/speed-test-go/internal/location"
+	"github.com/user/speed-test-go/internal/network"
+	"github.com/user/speed-test-go/pkg/types"
+)
+
+// locationCacheKey returns the cache key for the location detected by
+// locator over connections made with opts. Providers and network paths can
+// see different locations, so each combination is cached separately. The
+// default speedtest.net lookup keeps the key used before providers existed.
+func locationCacheKey(locator location.Provider, opts network.Options) string {
+	id := providerID(locator)
+	if id == providerID(location.NewSpeedtestProvider()) &&
+		opts.Interface == "" && opts.SourceAddr == "" && opts.Family == network.FamilyAny && opts.Proxy == "" {
+		return "location"
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		id, opts.Interface, opts.SourceAddr, string(opts.Family), opts.Proxy,
+	}, "\n")))
+	return "location-" + hex.EncodeToString(sum[:8])
+}
+
+// providerID describes a location provider by its name and configuration
+func providerID(p location.Provider) string {
+	switch p := p.(type) {
+	case location.Chain:
+		ids := make([]string, 0, len(p))
+		for _, provider := range p {
+			ids = append(ids, providerID(provider))
+		}
+		return strings.Join(ids, ",")
+	case *location.SpeedtestProvider:
+		return p.Name() + " " + p.URL
+	case *location.JSONProvider:
+		return fmt.Sprintf("%s %s %v", p.Name(), p.URL, p.Fields)
+	case *location.ManualProvider:
+		return fmt.Sprintf("%s %f %f", p.Name(), p.Latitude, p.Longitude)
+	default:
+		return p.Name()
+	}
+}
+
+// serverListCacheKey returns the cache key for a backend's server list,
+// separate for each endpoint of an EndpointBackend. The speedtest.net list
+// keeps the key used before backends existed, and the Cloudflare list the
+// key used before endpoints were configurable.
+func serverListCacheKey(backend Backend) string {
+	name := backend.Name()
+	if name == "speedtest" {
+		return "servers"
+	}
+
+	key := "servers-" + name
+	if eb, ok := backend.(EndpointBackend); ok && eb.Endpoint() != DefaultCloudflareURL {
+		sum := sha256.Sum256([]byte(eb.Endpoint()))
+		key += "-" + hex.EncodeToString(sum[:8])
+	}
+	return key
+}
+
+// fetchCached returns a fresh cached value for key when available, otherwise
+// calls fetch and caches its result. When fetch fails, a stale cached value
+// is returned instead of the error. The returned entry is nil when the value
+// came from the network.
+func fetchCached[T any](ctx context.Context, store *cache.Store, refresh bool, key string, fetch func(context.Context) (T, error)) (T, *types.CacheEntry, error) {
+	if store == nil {
+		v, err := fetch(ctx)
+		return v, nil, err
+	}
+
+	var cached T
+	age, loadErr := store.Load(key, &cached)
+	if loadErr == nil && !refresh && store.Fresh(age) {
+		return cached, cacheEntry(age, false), nil
+	}
+
+	v, err := fetch(ctx)
+	if err != nil {
+		if loadErr == nil {
+			// Prefer stale data over failing the whole run
+			return cached, cacheEntry(age, true), nil
+		}
+		return v, nil, err
+	}
+
+	// A failed write only costs the next run a refetch
+	_ = store.Save(key, v)
+
+	return v, nil, nil
+}
+
+func cacheEntry(age time.Duration, stale bool) *types.CacheEntry {
+	return &types.CacheEntry{
+		Age:   int64(age / time.Second),
+		Stale: stale,
+	}
+}
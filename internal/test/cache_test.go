@@ -0,0 +1,147 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/user/speed-test-go/internal/cache"
+	"github.com/user/speed-test-go/internal/location"
+	"github.com/user/speed-test-go/internal/network"
+)
+
+func TestFetchCached_NoStore(t *testing.T) {
+	calls := 0
+	fetch := func(ctx context.Context) (string, error) {
+		calls++
+		return "network", nil
+	}
+
+	v, entry, err := fetchCached(context.Background(), nil, false, "key", fetch)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if v != "network" || entry != nil || calls != 1 {
+		t.Errorf("Expected uncached fetch, got v=%q entry=%v calls=%d", v, entry, calls)
+	}
+}
+
+func TestFetchCached_FreshHit(t *testing.T) {
+	store := cache.NewStore(t.TempDir(), time.Hour)
+	if err := store.Save("key", "cached"); err != nil {
+		t.Fatal(err)
+	}
+
+	fetch := func(ctx context.Context) (string, error) {
+		t.Error("Expected fetch not to be called for fresh cache")
+		return "", nil
+	}
+
+	v, entry, err := fetchCached(context.Background(), store, false, "key", fetch)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if v != "cached" {
+		t.Errorf("Expected cached value, got: %q", v)
+	}
+	if entry == nil || entry.Stale {
+		t.Errorf("Expected fresh cache entry, got: %+v", entry)
+	}
+}
+
+func TestFetchCached_RefreshWritesCache(t *testing.T) {
+	store := cache.NewStore(t.TempDir(), time.Hour)
+	if err := store.Save("key", "old"); err != nil {
+		t.Fatal(err)
+	}
+
+	fetch := func(ctx context.Context) (string, error) {
+		return "new", nil
+	}
+
+	v, entry, err := fetchCached(context.Background(), store, true, "key", fetch)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if v != "new" || entry != nil {
+		t.Errorf("Expected fetched value without cache entry, got v=%q entry=%v", v, entry)
+	}
+
+	var stored string
+	if _, err := store.Load("key", &stored); err != nil || stored != "new" {
+		t.Errorf("Expected cache to be updated to %q, got %q (err %v)", "new", stored, err)
+	}
+}
+
+func TestFetchCached_StaleOnFailure(t *testing.T) {
+	store := cache.NewStore(t.TempDir(), 0) // every entry is stale
+	if err := store.Save("key", "stale"); err != nil {
+		t.Fatal(err)
+	}
+
+	fetch := func(ctx context.Context) (string, error) {
+		return "", errors.New("endpoint down")
+	}
+
+	v, entry, err := fetchCached(context.Background(), store, false, "key", fetch)
+	if err != nil {
+		t.Fatalf("Expected stale cache to hide fetch error, got: %v", err)
+	}
+	if v != "stale" {
+		t.Errorf("Expected stale value, got: %q", v)
+	}
+	if entry == nil || !entry.Stale {
+		t.Errorf("Expected stale cache entry, got: %+v", entry)
+	}
+}
+
+func TestFetchCached_FailureWithoutCache(t *testing.T) {
+	store := cache.NewStore(t.TempDir(), time.Hour)
+
+	fetch := func(ctx context.Context) (string, error) {
+		return "", errors.New("endpoint down")
+	}
+
+	if _, _, err := fetchCached(context.Background(), store, false, "key", fetch); err == nil {
+		t.Error("Expected error when fetch fails and nothing is cached")
+	}
+}
+
+func TestLocationCacheKey(t *testing.T) {
+	speedtest := location.NewSpeedtestProvider()
+	geo := location.NewJSONProvider("https://geo.example/json", location.DefaultFieldMapping)
+	other := location.NewJSONProvider("https://other.example/json", location.DefaultFieldMapping)
+
+	base := locationCacheKey(speedtest, network.Options{})
+	if base != "location" {
+		t.Errorf("Expected the default lookup to keep its key, got: %q", base)
+	}
+
+	tests := []struct {
+		name    string
+		locator location.Provider
+		opts    network.Options
+	}{
+		{"json provider", geo, network.Options{}},
+		{"other geo url", other, network.Options{}},
+		{"provider chain", location.Chain{speedtest, geo}, network.Options{}},
+		{"interface", speedtest, network.Options{Interface: "wwan0"}},
+		{"source address", speedtest, network.Options{SourceAddr: "192.0.2.10"}},
+		{"ipv6", speedtest, network.Options{Family: network.FamilyIPv6}},
+		{"proxy", speedtest, network.Options{Proxy: "http://proxy.internal:3128"}},
+	}
+
+	seen := map[string]string{base: "default"}
+	for _, tt := range tests {
+		key := locationCacheKey(tt.locator, tt.opts)
+		if prev, ok := seen[key]; ok {
+			t.Errorf("%s: key %q already used by %s", tt.name, key, prev)
+		}
+		seen[key] = tt.name
+
+		if again := locationCacheKey(tt.locator, tt.opts); again != key {
+			t.Errorf("%s: expected a stable key, got %q and %q", tt.name, key, again)
+		}
+	}
+}
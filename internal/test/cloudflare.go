@@ -0,0 +1,416 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/user/speed-test-go/internal/network"
+	"github.com/user/speed-test-go/internal/transfer"
+	"github.com/user/speed-test-go/pkg/types"
+)
+
+// DefaultCloudflareURL is the public Cloudflare speed test endpoint
+const DefaultCloudflareURL = "https://speed.cloudflare.com"
+
+// cloudflareStep is one entry of a sizing schedule: count transfers of size bytes
+type cloudflareStep struct {
+	size  int64
+	count int
+}
+
+// Sizing schedules grow the transfer size until a request takes long enough
+// to saturate the connection, like the Cloudflare speed test
+var (
+	cloudflareDownloadSchedule = []cloudflareStep{
+		{100_000, 10}, {1_000_000, 8}, {10_000_000, 6}, {25_000_000, 4}, {100_000_000, 3},
+	}
+	cloudflareUploadSchedule = []cloudflareStep{
+		{100_000, 8}, {1_000_000, 6}, {10_000_000, 4}, {25_000_000, 4}, {50_000_000, 3},
+	}
+)
+
+// CloudflareBackend measures an endpoint with the Cloudflare speed test API:
+// GET /__down?bytes=N, POST /__up and Server-Timing headers reporting the
+// server's own processing time, which is excluded from every sample
+type CloudflareBackend struct {
+	baseURL        string
+	numPings       int
+	testDuration   time.Duration
+	finishDuration time.Duration // stop growing sizes once a request takes this long
+	minDuration    time.Duration // shorter samples are too noisy to count
+	download       []cloudflareStep
+	upload         []cloudflareStep
+	samples        bool
+	progress       ProgressFunc
+	dataCap        *transfer.DataCap
+}
+
+// NewCloudflareBackend creates a backend for the endpoint at baseURL
+// (DefaultCloudflareURL when empty)
+func NewCloudflareBackend(baseURL string) *CloudflareBackend {
+	if baseURL == "" {
+		baseURL = DefaultCloudflareURL
+	}
+	return &CloudflareBackend{
+		baseURL:        strings.TrimRight(baseURL, "/"),
+		numPings:       20,
+		testDuration:   15 * time.Second,
+		finishDuration: 1 * time.Second,
+		minDuration:    10 * time.Millisecond,
+		download:       cloudflareDownloadSchedule,
+		upload:         cloudflareUploadSchedule,
+	}
+}
+
+// Name returns the backend name
+func (b *CloudflareBackend) Name() string {
+	return "cloudflare"
+}
+
+// Endpoint returns the base URL of the measured endpoint
+func (b *CloudflareBackend) Endpoint() string {
+	return b.baseURL
+}
+
+// SetSamples enables recording latency and throughput time series, one
+// sample per request
+func (b *CloudflareBackend) SetSamples(enabled bool) {
+	b.samples = enabled
+}
+
+// SetProgress reports the progress of transfers to fn after every request
+func (b *CloudflareBackend) SetProgress(fn ProgressFunc) {
+	b.progress = fn
+}
+
+// SetDataCap shrinks or skips requests once limit has been used up
+func (b *CloudflareBackend) SetDataCap(limit *transfer.DataCap) {
+	b.dataCap = limit
+}
+
+// cloudflareMeta is the subset of the /meta response describing the edge.
+// Its other fields, such as the city and coordinates, geolocate the client.
+type cloudflareMeta struct {
+	Colo string `json:"colo"` // IATA code of the data center, e.g. "AMS"
+}
+
+// Servers returns the single endpoint, named after its data center when /meta
+// reports one. The edge's coordinates are unknown, so it has no distance.
+func (b *CloudflareBackend) Servers(ctx context.Context, factory *network.Factory) ([]*types.Server, error) {
+	srv := &types.Server{
+		ID:      b.Name(),
+		URL:     b.baseURL,
+		Name:    b.baseURL,
+		Sponsor: "Cloudflare",
+	}
+	srv.Host = strings.TrimPrefix(strings.TrimPrefix(b.baseURL, "https://"), "http://")
+
+	// The endpoint is still usable when its data center is unknown
+	if meta, err := b.meta(ctx, factory.HTTPClient()); err == nil && meta.Colo != "" {
+		srv.Name = meta.Colo
+	}
+
+	return []*types.Server{srv}, nil
+}
+
+func (b *CloudflareBackend) meta(ctx context.Context, client *http.Client) (*cloudflareMeta, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", b.baseURL+"/meta", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned status %d", resp.StatusCode)
+	}
+
+	var meta cloudflareMeta
+	if err := json.NewDecoder(resp.Body).Decode(&meta); err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
+
+// Ping measures latency with empty downloads, excluding server processing time
+func (b *CloudflareBackend) Ping(ctx context.Context, factory *network.Factory, srv *types.Server) (*types.PingResult, error) {
+	client := factory.HTTPClient()
+
+	var series *transfer.LatencySeries
+	if b.samples {
+		series = transfer.NewLatencySeries()
+	}
+
+	latencies := make([]time.Duration, 0, b.numPings)
+	for i := 0; i < b.numPings; i++ {
+		sample, err := b.get(ctx, client, 0)
+		if err != nil {
+			if len(latencies) > 0 && ctx.Err() != nil {
+				break
+			}
+			return nil, err
+		}
+		latencies = append(latencies, sample.duration)
+		series.Add(sample.duration)
+	}
+
+	latency, jitter := CalculateLatency(latencies)
+	return &types.PingResult{
+		Latency: latency,
+		Jitter:  jitter,
+		Method:  "http",
+		Samples: len(latencies),
+		Series:  series.Samples(),
+	}, nil
+}
+
+// Download measures download throughput following the download schedule
+func (b *CloudflareBackend) Download(ctx context.Context, factory *network.Factory, srv *types.Server) (*types.TransferResult, error) {
+	client := factory.DownloadClient()
+	return b.measure(ctx, types.StateDownload, b.download, func(ctx context.Context, size int64) (cloudflareSample, error) {
+		return b.get(ctx, client, size)
+	})
+}
+
+// Upload measures upload throughput following the upload schedule
+func (b *CloudflareBackend) Upload(ctx context.Context, factory *network.Factory, srv *types.Server) (*types.TransferResult, error) {
+	client := factory.UploadClient()
+	return b.measure(ctx, types.StateUpload, b.upload, func(ctx context.Context, size int64) (cloudflareSample, error) {
+		return b.post(ctx, client, size)
+	})
+}
+
+// cloudflareSample is a single timed transfer
+type cloudflareSample struct {
+	bytes    int64
+	duration time.Duration // client-side time minus server processing time
+	protocol string
+}
+
+// measure runs transfers through the schedule until it is exhausted, the test
+// duration ends, or a step contains a request slower than finishDuration. The
+// bandwidth is the 90th percentile of the per-request rates.
+func (b *CloudflareBackend) measure(ctx context.Context, state types.OutputState, schedule []cloudflareStep, transfer func(context.Context, int64) (cloudflareSample, error)) (*types.TransferResult, error) {
+	testCtx, cancel := context.WithTimeout(ctx, b.testDuration)
+	defer cancel()
+
+	result := &types.TransferResult{}
+	var rates, allRates []float64
+	start := time.Now()
+
+schedule:
+	for _, step := range schedule {
+		finished := false
+		for i := 0; i < step.count; i++ {
+			size := b.dataCap.Claim(step.size)
+			if size == 0 {
+				break schedule
+			}
+
+			// Failed transfers still count the bytes they moved
+			sample, err := transfer(testCtx, size)
+			b.dataCap.Release(size - sample.bytes)
+			result.Bytes += sample.bytes
+			if err != nil {
+				if testCtx.Err() != nil {
+					break schedule
+				}
+				return nil, err
+			}
+
+			result.Protocol = sample.protocol
+			rate := float64(sample.bytes) / sample.duration.Seconds()
+			if b.samples {
+				result.Series = append(result.Series, types.TransferSample{
+					Elapsed: time.Since(start).Milliseconds(),
+					Bytes:   result.Bytes,
+					Rate:    rate,
+				})
+			}
+			b.report(state, start, result, rate, false)
+			allRates = append(allRates, rate)
+			if sample.duration >= b.minDuration {
+				rates = append(rates, rate)
+			}
+			if sample.duration >= b.finishDuration {
+				finished = true
+			}
+		}
+		if finished {
+			break
+		}
+	}
+	result.Elapsed = time.Since(start).Milliseconds()
+
+	if result.Bytes == 0 {
+		return nil, fmt.Errorf("no data transferred")
+	}
+	if len(rates) == 0 {
+		rates = allRates
+	}
+	result.Bandwidth = int64(percentile(rates, 0.9))
+	b.report(state, start, result, float64(result.Bandwidth), true)
+
+	return result, nil
+}
+
+// report sends the progress of a phase that started at start. The phase may
+// finish before the test duration ends, so its ETA is an upper bound and the
+// last report marks it done.
+func (b *CloudflareBackend) report(state types.OutputState, start time.Time, result *types.TransferResult, rate float64, done bool) {
+	if b.progress == nil {
+		return
+	}
+
+	elapsed := time.Since(start)
+	info := transfer.ProgressInfo{
+		Rate:       rate,
+		BytesTotal: result.Bytes,
+		Progress:   1,
+		Elapsed:    elapsed,
+		Protocol:   result.Protocol,
+	}
+	if !done && elapsed < b.testDuration {
+		info.Progress = float64(elapsed) / float64(b.testDuration)
+		info.ETA = b.testDuration - elapsed
+	}
+	b.progress(state, info)
+}
+
+// get downloads size bytes from /__down
+func (b *CloudflareBackend) get(ctx context.Context, client *http.Client, size int64) (cloudflareSample, error) {
+	url := b.baseURL + "/__down?bytes=" + strconv.FormatInt(size, 10)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return cloudflareSample{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	return b.do(client, req)
+}
+
+// post uploads size bytes to /__up
+func (b *CloudflareBackend) post(ctx context.Context, client *http.Client, size int64) (cloudflareSample, error) {
+	body := &countingReader{r: io.LimitReader(newPatternReader(), size)}
+	req, err := http.NewRequestWithContext(ctx, "POST", b.baseURL+"/__up", body)
+	if err != nil {
+		return cloudflareSample{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	// Count the bytes sent rather than the response, even if the upload failed
+	sample, err := b.do(client, req)
+	sample.bytes = body.n.Load()
+	return sample, err
+}
+
+// do sends req, reads the whole response and times it. On failure the sample
+// still holds the bytes read before the error.
+func (b *CloudflareBackend) do(client *http.Client, req *http.Request) (cloudflareSample, error) {
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return cloudflareSample{}, fmt.Errorf("request to %s failed: %w", req.URL.Path, err)
+	}
+	defer resp.Body.Close()
+
+	n, err := io.Copy(io.Discard, resp.Body)
+	if err != nil {
+		return cloudflareSample{bytes: n}, fmt.Errorf("failed to read response from %s: %w", req.URL.Path, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return cloudflareSample{bytes: n}, fmt.Errorf("%s returned status %d", req.URL.Path, resp.StatusCode)
+	}
+
+	duration := time.Since(start)
+	if server := serverTiming(resp.Header); server > 0 && server < duration {
+		duration -= server
+	}
+
+	return cloudflareSample{bytes: n, duration: duration, protocol: resp.Proto}, nil
+}
+
+// serverTiming sums the durations reported in Server-Timing headers,
+// e.g. "cfRequestDuration;dur=12.3"
+func serverTiming(header http.Header) time.Duration {
+	var total time.Duration
+	for _, value := range header.Values("Server-Timing") {
+		for _, metric := range strings.Split(value, ",") {
+			for _, param := range strings.Split(metric, ";") {
+				name, v, ok := strings.Cut(strings.TrimSpace(param), "=")
+				if !ok || name != "dur" {
+					continue
+				}
+				ms, err := strconv.ParseFloat(strings.Trim(v, `"`), 64)
+				if err == nil && ms > 0 {
+					total += time.Duration(ms * float64(time.Millisecond))
+				}
+			}
+		}
+	}
+	return total
+}
+
+// percentile returns the p-th percentile (0-1) of values by linear interpolation
+func percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	pos := p * float64(len(sorted)-1)
+	lower := int(pos)
+	if lower >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+	frac := pos - float64(lower)
+	return sorted[lower] + frac*(sorted[lower+1]-sorted[lower])
+}
+
+// patternReader produces an endless stream of incompressible bytes
+type patternReader struct {
+	pattern []byte
+	offset  int
+}
+
+func newPatternReader() *patternReader {
+	pattern := make([]byte, 64*1024)
+	rand.Read(pattern)
+	return &patternReader{pattern: pattern}
+}
+
+func (r *patternReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		copied := copy(p[n:], r.pattern[r.offset:])
+		n += copied
+		r.offset = (r.offset + copied) % len(r.pattern)
+	}
+	return n, nil
+}
+
+// countingReader counts the bytes read from r. The HTTP transport may still be
+// reading a request body when a failed request returns, so the count is atomic.
+type countingReader struct {
+	r io.Reader
+	n atomic.Int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,326 @@
+package test
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/user/speed-test-go/internal/network"
+	"github.com/user/speed-test-go/internal/transfer"
+	"github.com/user/speed-test-go/pkg/types"
+)
+
+// newCloudflareServer starts a stand-in for the Cloudflare speed test API
+func newCloudflareServer(t *testing.T) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+
+	var uploaded atomic.Int64
+	mux := http.NewServeMux()
+	mux.HandleFunc("/__down", func(w http.ResponseWriter, r *http.Request) {
+		size, err := strconv.Atoi(r.URL.Query().Get("bytes"))
+		if err != nil {
+			http.Error(w, "bad size", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Server-Timing", "cfRequestDuration;dur=0.5")
+		w.Write(make([]byte, size))
+	})
+	mux.HandleFunc("/__up", func(w http.ResponseWriter, r *http.Request) {
+		n, _ := io.Copy(io.Discard, r.Body)
+		uploaded.Add(n)
+		w.Header().Set("Server-Timing", "cfRequestDuration;dur=0.5")
+	})
+	mux.HandleFunc("/meta", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"city":"Amsterdam","country":"NL","colo":"AMS","latitude":"52.37","longitude":"4.90"}`))
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server, &uploaded
+}
+
+// smallCloudflareBackend returns a backend with a schedule sized for tests
+func smallCloudflareBackend(url string) *CloudflareBackend {
+	b := NewCloudflareBackend(url)
+	b.numPings = 5
+	b.download = []cloudflareStep{{1000, 2}, {10000, 2}}
+	b.upload = []cloudflareStep{{1000, 2}, {10000, 2}}
+	return b
+}
+
+func TestCloudflareBackend_Servers(t *testing.T) {
+	server, _ := newCloudflareServer(t)
+
+	servers, err := NewCloudflareBackend(server.URL+"/").Servers(context.Background(), network.DefaultFactory())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(servers) != 1 {
+		t.Fatalf("Expected a single server, got %d", len(servers))
+	}
+
+	srv := servers[0]
+	if srv.ID != "cloudflare" || srv.URL != server.URL {
+		t.Errorf("Unexpected server: %+v", srv)
+	}
+	// /meta locates the client, so only the data center describes the edge
+	if srv.Name != "AMS" || srv.CC != "" || srv.Lat != "" || srv.Lon != "" {
+		t.Errorf("Expected the data center from /meta and no coordinates, got: %+v", srv)
+	}
+}
+
+func TestServerListCacheKey(t *testing.T) {
+	tests := []struct {
+		backend Backend
+		want    string
+	}{
+		{&SpeedtestBackend{protocol: ProtocolHTTP}, "servers"},
+		{NewCloudflareBackend(""), "servers-cloudflare"},
+		{NewCloudflareBackend(DefaultCloudflareURL + "/"), "servers-cloudflare"},
+	}
+	for _, tt := range tests {
+		if got := serverListCacheKey(tt.backend); got != tt.want {
+			t.Errorf("serverListCacheKey(%s) = %q, want %q", tt.backend.Name(), got, tt.want)
+		}
+	}
+
+	// Each endpoint keeps its own server list
+	a := serverListCacheKey(NewCloudflareBackend("https://speed.example.net"))
+	b := serverListCacheKey(NewCloudflareBackend("https://speed.example.org"))
+	if a == b || a == "servers-cloudflare" || !strings.HasPrefix(a, "servers-cloudflare-") {
+		t.Errorf("Expected distinct keys per endpoint, got %q and %q", a, b)
+	}
+}
+
+func TestCloudflareBackend_Measure(t *testing.T) {
+	server, uploaded := newCloudflareServer(t)
+	b := smallCloudflareBackend(server.URL)
+	factory := network.DefaultFactory()
+	ctx := context.Background()
+
+	servers, err := b.Servers(ctx, factory)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	ping, err := b.Ping(ctx, factory, servers[0])
+	if err != nil {
+		t.Fatalf("Ping failed: %v", err)
+	}
+	if ping.Samples != 5 || ping.Latency <= 0 {
+		t.Errorf("Unexpected ping result: %+v", ping)
+	}
+
+	download, err := b.Download(ctx, factory, servers[0])
+	if err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	if download.Bytes != 22000 || download.Bandwidth <= 0 || download.Protocol != "HTTP/1.1" {
+		t.Errorf("Unexpected download result: %+v", download)
+	}
+
+	upload, err := b.Upload(ctx, factory, servers[0])
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if upload.Bytes != 22000 || uploaded.Load() != 22000 {
+		t.Errorf("Expected 22000 bytes uploaded, got result %d and server %d", upload.Bytes, uploaded.Load())
+	}
+}
+
+func TestCloudflareBackend_Samples(t *testing.T) {
+	server, _ := newCloudflareServer(t)
+	b := smallCloudflareBackend(server.URL)
+	b.SetSamples(true)
+	factory := network.DefaultFactory()
+	ctx := context.Background()
+
+	ping, err := b.Ping(ctx, factory, nil)
+	if err != nil {
+		t.Fatalf("Ping failed: %v", err)
+	}
+	if len(ping.Series) != 5 {
+		t.Errorf("Expected a latency sample per probe, got %d", len(ping.Series))
+	}
+
+	download, err := b.Download(ctx, factory, nil)
+	if err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	if len(download.Series) != 4 {
+		t.Fatalf("Expected a throughput sample per request, got %d", len(download.Series))
+	}
+	if last := download.Series[3]; last.Bytes != download.Bytes || last.Rate <= 0 {
+		t.Errorf("Unexpected last sample: %+v", last)
+	}
+}
+
+func TestCloudflareBackend_Progress(t *testing.T) {
+	server, _ := newCloudflareServer(t)
+	b := smallCloudflareBackend(server.URL)
+
+	var updates []transfer.ProgressInfo
+	b.SetProgress(func(state types.OutputState, info transfer.ProgressInfo) {
+		if state != types.StateDownload {
+			t.Errorf("Expected download progress, got state %v", state)
+		}
+		updates = append(updates, info)
+	})
+
+	download, err := b.Download(context.Background(), network.DefaultFactory(), nil)
+	if err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+
+	// One update per request plus the final one
+	if len(updates) != 5 {
+		t.Fatalf("Expected 5 progress updates, got %d", len(updates))
+	}
+	if first := updates[0]; first.Progress <= 0 || first.Progress >= 1 || first.ETA <= 0 {
+		t.Errorf("Expected a partial first update, got %+v", first)
+	}
+	if last := updates[4]; last.Progress != 1 || last.ETA != 0 || last.BytesTotal != download.Bytes {
+		t.Errorf("Expected a complete last update, got %+v", last)
+	}
+}
+
+func TestCloudflareBackend_DataCap(t *testing.T) {
+	server, uploaded := newCloudflareServer(t)
+	b := smallCloudflareBackend(server.URL)
+	limit := transfer.NewDataCap(15000)
+	b.SetDataCap(limit)
+	factory := network.DefaultFactory()
+
+	// The schedule would download 22000 bytes
+	download, err := b.Download(context.Background(), factory, nil)
+	if err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	if download.Bytes != 15000 || !limit.Reached() {
+		t.Errorf("Expected the cap to limit the download to 15000 bytes, got %d", download.Bytes)
+	}
+
+	if _, err := b.Upload(context.Background(), factory, nil); err == nil {
+		t.Error("Expected an error when the cap leaves nothing to upload")
+	}
+	if uploaded.Load() != 0 {
+		t.Errorf("Expected no upload past the cap, got %d bytes", uploaded.Load())
+	}
+}
+
+func TestCloudflareBackend_PartialTransfer(t *testing.T) {
+	// The connection drops after half of each download
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		size, _ := strconv.Atoi(r.URL.Query().Get("bytes"))
+		w.Header().Set("Content-Length", strconv.Itoa(size))
+		w.Write(make([]byte, size/2))
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}))
+	defer server.Close()
+
+	b := smallCloudflareBackend(server.URL)
+	limit := transfer.NewDataCap(100000)
+	b.SetDataCap(limit)
+
+	if _, err := b.Download(context.Background(), network.DefaultFactory(), nil); err == nil {
+		t.Fatal("Expected the interrupted download to fail")
+	}
+	if limit.Used() != 500 {
+		t.Errorf("Expected the 500 bytes received to stay charged to the cap, got %d", limit.Used())
+	}
+
+	// A failed upload only counts the bytes it sent
+	upServer, _ := newCloudflareServer(t)
+	b.baseURL = upServer.URL
+	sample, err := b.post(context.Background(), network.DefaultFactory().UploadClient(), 1000)
+	if err != nil {
+		t.Fatalf("Unexpected upload error: %v", err)
+	}
+	if sample.bytes != 1000 {
+		t.Errorf("Expected 1000 bytes sent, got %d", sample.bytes)
+	}
+	b.baseURL = "http://127.0.0.1:1"
+	sample, err = b.post(context.Background(), network.DefaultFactory().UploadClient(), 1000)
+	if err == nil || sample.bytes != 0 {
+		t.Errorf("Expected a refused upload to send nothing, got %d bytes, err %v", sample.bytes, err)
+	}
+}
+
+func TestCloudflareBackend_FinishDuration(t *testing.T) {
+	server, _ := newCloudflareServer(t)
+	b := smallCloudflareBackend(server.URL)
+
+	// Every request counts as slow, so only the first step runs
+	b.finishDuration = time.Nanosecond
+	download, err := b.Download(context.Background(), network.DefaultFactory(), nil)
+	if err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	if download.Bytes != 2000 {
+		t.Errorf("Expected schedule to stop after the first step, got %d bytes", download.Bytes)
+	}
+}
+
+func TestCloudflareBackend_Error(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	b := smallCloudflareBackend(server.URL)
+	if _, err := b.Download(context.Background(), network.DefaultFactory(), nil); err == nil {
+		t.Error("Expected error for endpoint without the speed test API")
+	}
+}
+
+func TestServerTiming(t *testing.T) {
+	tests := []struct {
+		values []string
+		want   time.Duration
+	}{
+		{nil, 0},
+		{[]string{"cfRequestDuration;dur=12.5"}, 12500 * time.Microsecond},
+		{[]string{`cache;desc="hit";dur=1, app;dur=2`}, 3 * time.Millisecond},
+		{[]string{"cfRequestDuration;dur=1", "edge;dur=2"}, 3 * time.Millisecond},
+		{[]string{"miss"}, 0},
+	}
+
+	for _, tt := range tests {
+		header := http.Header{}
+		for _, v := range tt.values {
+			header.Add("Server-Timing", v)
+		}
+		if got := serverTiming(header); got != tt.want {
+			t.Errorf("serverTiming(%v) = %v, want %v", tt.values, got, tt.want)
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	values := []float64{50, 10, 40, 20, 30}
+
+	tests := []struct {
+		p    float64
+		want float64
+	}{
+		{0, 10},
+		{0.5, 30},
+		{0.9, 46},
+		{1, 50},
+	}
+	for _, tt := range tests {
+		if got := percentile(values, tt.p); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("percentile(%v) = %f, want %f", tt.p, got, tt.want)
+		}
+	}
+
+	if percentile(nil, 0.9) != 0 {
+		t.Error("Expected 0 for no values")
+	}
+}
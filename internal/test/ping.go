@@ -9,25 +9,44 @@ import (
 	"time"
 
 	"github.com/user/speed-test-go/internal/network"
+	"github.com/user/speed-test-go/internal/transfer"
 	"github.com/user/speed-test-go/pkg/types"
 )
 
 // PingTest measures latency to a server
 type PingTest struct {
-	client      *http.Client
-	numPings    int
-	pingTimeout time.Duration
+	client       *http.Client
+	numPings     int
+	pingTimeout  time.Duration
+	useWebSocket bool
+	series       *transfer.LatencySeries
 }
 
 // NewPingTest creates a new ping test instance
 func NewPingTest() *PingTest {
 	return &PingTest{
-		client:      network.NewHTTPClient(),
-		numPings:    5,
-		pingTimeout: 5 * time.Second,
+		client:       network.DefaultFactory().HTTPClient(),
+		numPings:     5,
+		pingTimeout:  5 * time.Second,
+		useWebSocket: true,
 	}
 }
 
+// SetClient sets the HTTP client used for pings
+func (pt *PingTest) SetClient(client *http.Client) {
+	pt.client = client
+}
+
+// SetWebSocket enables or disables the WebSocket probe tried before HTTP pings
+func (pt *PingTest) SetWebSocket(enabled bool) {
+	pt.useWebSocket = enabled
+}
+
+// SetLatencySeries records every ping in series
+func (pt *PingTest) SetLatencySeries(series *transfer.LatencySeries) {
+	pt.series = series
+}
+
 // Run executes the ping test and returns latency measurements
 func (pt *PingTest) Run(ctx context.Context, serverURL string) ([]time.Duration, error) {
 	latencyURL := fmt.Sprintf("%s/speedtest/latency.txt", serverURL)
@@ -62,7 +81,6 @@ func (pt *PingTest) Run(ctx context.Context, serverURL string) ([]time.Duration,
 			if err != nil {
 				return
 			}
-			req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
 
 			resp, err := pt.client.Do(req)
 			if err != nil {
@@ -75,6 +93,7 @@ func (pt *PingTest) Run(ctx context.Context, serverURL string) ([]time.Duration,
 				mu.Lock()
 				latencies = append(latencies, latency)
 				mu.Unlock()
+				pt.series.Add(latency)
 			}
 		}(i)
 	}
@@ -111,10 +130,29 @@ func CalculateLatency(latencies []time.Duration) (float64, float64) {
 
 // RunPingTest is a convenience function to run a complete ping test
 func RunPingTest(ctx context.Context, serverURL string) (*types.PingResult, error) {
-	pt := NewPingTest()
-	latencies, err := pt.Run(ctx, serverURL)
-	if err != nil {
-		return nil, err
+	return NewPingTest().Measure(ctx, serverURL)
+}
+
+// Measure runs the ping test and summarizes latency and jitter.
+// A WebSocket probe is tried first; servers without WebSocket support are
+// measured with HTTP requests for latency.txt instead.
+func (pt *PingTest) Measure(ctx context.Context, serverURL string) (*types.PingResult, error) {
+	method := "websocket"
+	var latencies []time.Duration
+	var err error
+	if pt.useWebSocket {
+		wp := NewWebSocketPing(pt.client)
+		wp.SetLatencySeries(pt.series)
+		latencies, err = wp.Run(ctx, serverURL)
+	}
+
+	if !pt.useWebSocket || err != nil || len(latencies) == 0 {
+		method = "http"
+		pt.series.Reset()
+		latencies, err = pt.Run(ctx, serverURL)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// Return error if no successful pings
@@ -127,5 +165,8 @@ func RunPingTest(ctx context.Context, serverURL string) (*types.PingResult, erro
 	return &types.PingResult{
 		Jitter:  jitter,
 		Latency: latency,
+		Method:  method,
+		Samples: len(latencies),
+		Series:  pt.series.Samples(),
 	}, nil
 }
@@ -8,6 +8,10 @@ import (
 	"sync"
 	"testing"
 	"time"
+
+	"github.com/user/speed-test-go/internal/location"
+	"github.com/user/speed-test-go/internal/network"
+	"github.com/user/speed-test-go/pkg/types"
 )
 
 func TestNewPingTest(t *testing.T) {
@@ -487,3 +491,51 @@ func TestCalculateLatency_NilSlice(t *testing.T) {
 		t.Errorf("Expected jitter 0 for nil slice, got: %f", jitter)
 	}
 }
+
+func TestHTTP2_DiscoveryAndLatencyOverHTTP1(t *testing.T) {
+	// The server only speaks HTTP/1.1, like speedtest.net and its servers
+	var protocols []string
+	var mu sync.Mutex
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		protocols = append(protocols, r.Proto)
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/speedtest-config.php":
+			w.Write([]byte(`<settings><server-config ip="192.0.2.1" lat="52.37" lon="4.90" isp="Test ISP" /></settings>`))
+		case "/speedtest/latency.txt":
+			w.Write([]byte("test=test"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	server.Config.Protocols = new(http.Protocols)
+	server.Config.Protocols.SetHTTP1(true)
+	server.Start()
+	defer server.Close()
+
+	factory, err := network.NewFactory(network.Options{HTTP2: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	provider := location.NewSpeedtestProvider()
+	provider.URL = server.URL + "/speedtest-config.php"
+	provider.Client = factory.Client(location.LocateTimeout)
+	if _, err := provider.Locate(context.Background()); err != nil {
+		t.Fatalf("Location detection failed with HTTP/2 enabled: %v", err)
+	}
+
+	srv := &types.Server{ID: "1", URL: server.URL + "/speedtest/upload.php"}
+	if _, err := (&SpeedtestBackend{protocol: ProtocolHTTP}).Ping(context.Background(), factory, srv); err != nil {
+		t.Fatalf("Latency test failed with HTTP/2 enabled: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, proto := range protocols {
+		if proto != "HTTP/1.1" {
+			t.Errorf("Expected discovery and latency requests over HTTP/1.1, got: %s", proto)
+		}
+	}
+}
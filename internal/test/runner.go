@@ -3,10 +3,13 @@ package test
 import (
 	"context"
 	"fmt"
+	"net"
 	"strconv"
 	"time"
 
+	"github.com/user/speed-test-go/internal/cache"
 	"github.com/user/speed-test-go/internal/location"
+	"github.com/user/speed-test-go/internal/network"
 	"github.com/user/speed-test-go/internal/server"
 	"github.com/user/speed-test-go/internal/transfer"
 	"github.com/user/speed-test-go/pkg/types"
@@ -17,6 +20,22 @@ type Runner struct {
 	maxServers       int
 	serverID         string
 	numServersToTest int
+	filter           server.Filter
+	compareIDs       []string
+	compareTop       int
+	serverTimeout    time.Duration
+	cache            *cache.Store
+	refresh          bool
+	serversFile      string
+	location         *types.UserLocation
+	locator          location.Provider
+	factory          *network.Factory
+	backend          Backend
+	samples          bool
+	progress         ProgressFunc
+	downloadBytes    int64
+	uploadBytes      int64
+	dataCap          *transfer.DataCap
 }
 
 // NewRunner creates a new test runner
@@ -24,6 +43,51 @@ func NewRunner() *Runner {
 	return &Runner{
 		maxServers:       5,
 		numServersToTest: 5,
+		factory:          network.DefaultFactory(),
+		backend:          &SpeedtestBackend{protocol: ProtocolHTTP},
+	}
+}
+
+// SetClientFactory sets the factory that builds the HTTP clients for every phase
+func (r *Runner) SetClientFactory(factory *network.Factory) {
+	if factory != nil {
+		r.factory = factory
+	}
+}
+
+// SetBackend sets the backend that discovers and measures servers
+func (r *Runner) SetBackend(backend Backend) {
+	if backend != nil {
+		r.backend = backend
+	}
+}
+
+// SetSamples enables recording latency and throughput time series, if the
+// backend supports them
+func (r *Runner) SetSamples(enabled bool) {
+	r.samples = enabled
+}
+
+// SetProgress reports each phase as it starts, and the progress of transfers
+// if the backend supports it, to fn
+func (r *Runner) SetProgress(fn ProgressFunc) {
+	r.progress = fn
+}
+
+// SetTransferBytes makes the download and upload transfer exactly these many
+// bytes instead of running for a fixed duration; 0 keeps a phase timed. The
+// backend must implement TransferSizer.
+func (r *Runner) SetTransferBytes(download, upload int64) {
+	r.downloadBytes, r.uploadBytes = download, upload
+}
+
+// SetMaxData caps the bytes transferred by every phase of the runner's tests
+// together, stopping transfers and marking results truncated once it is
+// reached; 0 removes the cap. The backend must implement DataLimiter.
+func (r *Runner) SetMaxData(limit int64) {
+	r.dataCap = nil
+	if limit > 0 {
+		r.dataCap = transfer.NewDataCap(limit)
 	}
 }
 
@@ -39,28 +103,297 @@ func (r *Runner) SetNumServersToTest(n int) {
 	}
 }
 
+// SetServerFilter restricts automatic server selection to servers matching the filter
+func (r *Runner) SetServerFilter(filter server.Filter) {
+	r.filter = filter
+}
+
+// SetCompareServers sets explicit server IDs to test in comparison mode
+func (r *Runner) SetCompareServers(ids []string) {
+	r.compareIDs = ids
+}
+
+// SetCompareTop sets the number of closest servers to test in comparison mode
+func (r *Runner) SetCompareTop(n int) {
+	if n > 0 {
+		r.compareTop = n
+	}
+}
+
+// SetServerTimeout limits the test against each server in comparison mode,
+// and each address family in dual-stack mode, so one slow pass cannot use up
+// the time of the ones after it; 0 means no limit. Discovery gets the same
+// limit.
+func (r *Runner) SetServerTimeout(timeout time.Duration) {
+	r.serverTimeout = timeout
+}
+
+// SetCache sets the cache used for the server list and user location.
+// A nil store disables caching.
+func (r *Runner) SetCache(store *cache.Store) {
+	r.cache = store
+}
+
+// SetRefresh forces cached discovery data to be refetched
+func (r *Runner) SetRefresh(refresh bool) {
+	r.refresh = refresh
+}
+
+// SetServersFile loads servers from a JSON file instead of speedtest.net
+func (r *Runner) SetServersFile(path string) {
+	r.serversFile = path
+}
+
+// SetLocation sets the user's coordinates, skipping location detection
+func (r *Runner) SetLocation(lat, lon float64) {
+	r.location = &types.UserLocation{
+		Latitude:  lat,
+		Longitude: lon,
+	}
+}
+
+// SetLocationProvider sets the provider used to detect the user's location.
+// Use a location.Chain to fall back across several providers.
+func (r *Runner) SetLocationProvider(p location.Provider) {
+	r.locator = p
+}
+
+// IsComparison reports whether the runner is configured for comparison mode
+func (r *Runner) IsComparison() bool {
+	return len(r.compareIDs) > 0 || r.compareTop > 0
+}
+
 // Run executes the complete speed test
 func (r *Runner) Run(ctx context.Context) (*types.SpeedTestResult, error) {
 	result := &types.SpeedTestResult{
 		Timestamp: time.Now(),
 	}
 
-	// Step 1: Detect user location
-	loc, err := location.DetectUserLocation(ctx)
+	servers, err := r.discover(ctx, result)
+	if err != nil {
+		return nil, err
+	}
+
+	// Step 3: Select best server
+	bestServer, err := r.selectServer(ctx, servers)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.runServer(ctx, r.factory, result, bestServer); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// RunDualStack runs the test sequence against the same server once over IPv4
+// and once over IPv6. A family that fails is recorded in its result without
+// stopping the other.
+func (r *Runner) RunDualStack(ctx context.Context) (*types.ComparisonResult, error) {
+	base := &types.SpeedTestResult{}
+	comparison := &types.ComparisonResult{
+		Timestamp: time.Now(),
+	}
+
+	discoverCtx, cancel := r.serverContext(ctx)
+	defer cancel()
+	servers, err := r.discover(discoverCtx, base)
+	if err != nil {
+		return nil, err
+	}
+
+	srv, err := r.selectServer(discoverCtx, servers)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, family := range []network.Family{network.FamilyIPv4, network.FamilyIPv6} {
+		result := &types.SpeedTestResult{
+			Timestamp:     time.Now(),
+			Interface:     base.Interface,
+			ISP:           base.ISP,
+			Cache:         base.Cache,
+			AddressFamily: string(family),
+		}
+
+		// Each family gets its own deadline
+		factory, err := r.factory.WithFamily(family)
+		if err == nil {
+			familyCtx, cancel := r.serverContext(ctx)
+			err = r.runServer(familyCtx, factory, result, srv)
+			cancel()
+		}
+		if err != nil {
+			result.Server = serverInfo(srv)
+			result.Error = err.Error()
+		}
+
+		comparison.Results = append(comparison.Results, result)
+	}
+
+	return comparison, nil
+}
+
+// RunComparison runs the full test sequence against several servers in turn.
+// A failure against one server is recorded in its result and does not stop
+// the remaining servers from being tested.
+func (r *Runner) RunComparison(ctx context.Context) (*types.ComparisonResult, error) {
+	base := &types.SpeedTestResult{}
+	comparison := &types.ComparisonResult{
+		Timestamp: time.Now(),
+	}
+
+	discoverCtx, cancel := r.serverContext(ctx)
+	servers, err := r.discover(discoverCtx, base)
+	cancel()
 	if err != nil {
-		return nil, fmt.Errorf("failed to detect location: %w", err)
+		return nil, err
+	}
+
+	targets, err := r.comparisonTargets(servers)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, srv := range targets {
+		result := &types.SpeedTestResult{
+			Timestamp: time.Now(),
+			Interface: base.Interface,
+			ISP:       base.ISP,
+			Cache:     base.Cache,
+		}
+		// Servers left when the run is cancelled are recorded as failed
+		err := ctx.Err()
+		if err == nil {
+			serverCtx, cancel := r.serverContext(ctx)
+			err = r.runServer(serverCtx, r.factory, result, srv)
+			cancel()
+		}
+		if err != nil {
+			result.Server = serverInfo(srv)
+			result.Error = err.Error()
+		}
+		comparison.Results = append(comparison.Results, result)
+	}
+
+	return comparison, nil
+}
+
+// serverContext returns the context for testing a single server, limited
+// to the server timeout if one is set
+func (r *Runner) serverContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.serverTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, r.serverTimeout)
+}
+
+// selectServer picks the server to test, either by ID or by pinging the closest candidates
+func (r *Runner) selectServer(ctx context.Context, servers []*types.Server) (*types.Server, error) {
+	if r.serverID != "" {
+		// Use specified server ID
+		srv := server.FindServerByID(servers, r.serverID)
+		if srv == nil {
+			return nil, fmt.Errorf("server with ID %s not found", r.serverID)
+		}
+		return srv, nil
+	}
+
+	// Drop servers excluded by the filter before selection
+	candidates := server.FilterServers(servers, r.filter)
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no servers match the server filter")
+	}
+
+	// Auto-select best server, e.g. by pinging the closest servers
+	selector, ok := r.backend.(ServerSelector)
+	if !ok {
+		return candidates[0], nil
+	}
+	bestServer, err := selector.SelectServer(ctx, r.factory, candidates, r.numServersToTest)
+	if err != nil {
+		// Fall back to closest server
+		return candidates[0], nil
+	}
+	return bestServer, nil
+}
+
+// comparisonTargets resolves the servers to test in comparison mode
+func (r *Runner) comparisonTargets(servers []*types.Server) ([]*types.Server, error) {
+	if len(r.compareIDs) > 0 {
+		targets := make([]*types.Server, 0, len(r.compareIDs))
+		for _, id := range r.compareIDs {
+			srv := server.FindServerByID(servers, id)
+			if srv == nil {
+				return nil, fmt.Errorf("server with ID %s not found", id)
+			}
+			targets = append(targets, srv)
+		}
+		return targets, nil
+	}
+
+	candidates := server.FilterServers(servers, r.filter)
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no servers match the server filter")
+	}
+	if r.compareTop < len(candidates) {
+		candidates = candidates[:r.compareTop]
+	}
+	return candidates, nil
+}
+
+// discover detects the user location and returns the server list sorted by distance
+func (r *Runner) discover(ctx context.Context, result *types.SpeedTestResult) ([]*types.Server, error) {
+	// Step 1: Detect user location, unless given explicitly
+	loc := r.location
+	var locCache *types.CacheEntry
+	if loc == nil {
+		locator := r.locator
+		if locator == nil {
+			provider := location.NewSpeedtestProvider()
+			provider.Client = r.factory.Client(location.LocateTimeout)
+			locator = provider
+		}
+
+		var err error
+		loc, locCache, err = fetchCached(ctx, r.cache, r.refresh, locationCacheKey(locator, r.factory.Options()), locator.Locate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to detect location: %w", err)
+		}
 	}
 	result.Interface = &types.InterfaceInfo{
 		ExternalIP: loc.IP,
 	}
 	result.ISP = loc.ISP
 
-	// Step 2: Fetch and sort servers
-	servers, err := server.FetchServerList(ctx)
+	// Step 2: Fetch and sort the backend's servers, from a local file when configured
+	var servers []*types.Server
+	var serversCache *types.CacheEntry
+	var err error
+	if r.serversFile != "" {
+		servers, err = server.LoadServerFile(r.serversFile)
+	} else {
+		fetch := func(ctx context.Context) ([]*types.Server, error) {
+			return r.backend.Servers(ctx, r.factory)
+		}
+		key := serverListCacheKey(r.backend)
+		servers, serversCache, err = fetchCached(ctx, r.cache, r.refresh, key, fetch)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch servers: %w", err)
 	}
 
+	if locCache != nil || serversCache != nil {
+		result.Cache = &types.CacheInfo{
+			Location:   locCache,
+			ServerList: serversCache,
+		}
+	}
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("no servers available")
+	}
+
 	userLat, _ := parseCoordinate(loc.Latitude)
 	userLon, _ := parseCoordinate(loc.Longitude)
 	server.CalculateServerDistances(servers, userLat, userLon)
@@ -68,67 +401,105 @@ func (r *Runner) Run(ctx context.Context) (*types.SpeedTestResult, error) {
 	// Sort by distance
 	server.SortServersByDistance(servers)
 
-	// Step 3: Select best server
-	var bestServer *types.Server
-	var selErr error
+	return servers, nil
+}
 
-	if r.serverID != "" {
-		// Use specified server ID
-		bestServer = server.FindServerByID(servers, r.serverID)
-		if bestServer == nil {
-			return nil, fmt.Errorf("server with ID %s not found", r.serverID)
+// runServer runs the backend's ping, download and upload phases against a
+// single server using connections from factory
+func (r *Runner) runServer(ctx context.Context, factory *network.Factory, result *types.SpeedTestResult, srv *types.Server) error {
+	result.Insecure = factory.Insecure()
+	if r.downloadBytes > 0 || r.uploadBytes > 0 {
+		sizer, ok := r.backend.(TransferSizer)
+		if !ok {
+			return fmt.Errorf("the %s backend does not support fixed-size transfers", r.backend.Name())
 		}
-	} else {
-		// Auto-select best server by pinging closest servers
-		bestServer, selErr = server.SelectBestServerByPing(ctx, servers, r.numServersToTest)
-		if selErr != nil {
-			// Fall back to closest server
-			bestServer = servers[0]
+		sizer.SetTransferBytes(r.downloadBytes, r.uploadBytes)
+	}
+	if r.dataCap != nil {
+		limiter, ok := r.backend.(DataLimiter)
+		if !ok {
+			return fmt.Errorf("the %s backend does not support data caps", r.backend.Name())
 		}
+		limiter.SetDataCap(r.dataCap)
+	}
+	if recorder, ok := r.backend.(SampleRecorder); ok && r.samples {
+		recorder.SetSamples(true)
+	}
+	if notifier, ok := r.backend.(ProgressNotifier); ok && r.progress != nil {
+		notifier.SetProgress(r.progress)
 	}
 
-	serverURL := server.GetServerBaseURL(bestServer)
+	// Time the server lookup; a failure here surfaces in the ping test
+	if dns, err := factory.ResolveHost(ctx, server.GetServerHost(srv)); err == nil {
+		result.DNS = dns
+	}
 
 	// Step 4: Run ping test
-	pingResult, err := RunPingTest(ctx, serverURL)
+	r.report(types.StatePing)
+	pingResult, err := r.backend.Ping(ctx, factory, srv)
 	if err != nil {
-		return nil, fmt.Errorf("ping test failed: %w", err)
+		return fmt.Errorf("ping test failed: %w", err)
 	}
 	result.Ping = *pingResult
 
-	// Step 5: Run download test
-	downloadResult, err := transfer.RunSimpleDownloadTest(ctx, serverURL)
-	if err != nil {
-		return nil, fmt.Errorf("download test failed: %w", err)
-	}
-	result.Download = types.TransferResult{
-		Bandwidth: downloadResult.Bandwidth,
-		Bytes:     downloadResult.Bytes,
-		Elapsed:   downloadResult.Elapsed.Milliseconds(),
+	// Step 5: Run download test, unless the data cap is used up
+	if !r.dataCap.Reached() {
+		r.report(types.StateDownload)
+		downloadResult, err := r.backend.Download(ctx, factory, srv)
+		if err != nil {
+			return fmt.Errorf("download test failed: %w", err)
+		}
+		result.Download = *downloadResult
 	}
 
-	// Step 6: Run upload test
-	uploadResult, err := transfer.RunSimpleUploadTest(ctx, serverURL)
-	if err != nil {
-		return nil, fmt.Errorf("upload test failed: %w", err)
+	// Step 6: Run upload test, unless the data cap is used up
+	if !r.dataCap.Reached() {
+		r.report(types.StateUpload)
+		uploadResult, err := r.backend.Upload(ctx, factory, srv)
+		if err != nil {
+			return fmt.Errorf("upload test failed: %w", err)
+		}
+		result.Upload = *uploadResult
 	}
-	result.Upload = types.TransferResult{
-		Bandwidth: uploadResult.Bandwidth,
-		Bytes:     uploadResult.Bytes,
-		Elapsed:   uploadResult.Elapsed.Milliseconds(),
+	r.report(types.StateDone)
+
+	result.DataUsed = result.Download.Bytes + result.Upload.Bytes
+	result.Truncated = r.dataCap.Reached()
+
+	// Step 7: Populate server info, and local interface info when the
+	// server was reached directly
+	result.Server = serverInfo(srv)
+	if info, err := factory.DescribeInterface(server.GetServerHost(srv)); err == nil {
+		if result.Interface != nil {
+			info.ExternalIP = result.Interface.ExternalIP
+		}
+		result.Interface = info
+
+		if ip := net.ParseIP(info.InternalIP); ip != nil {
+			result.AddressFamily = string(network.FamilyOf(ip))
+		}
 	}
 
-	// Step 7: Populate server info
-	result.Server = &types.ServerInfo{
-		ID:       bestServer.ID,
-		Host:     bestServer.Host,
-		Name:     bestServer.Name,
-		Country:  bestServer.Country,
-		Sponsor:  bestServer.Sponsor,
-		Distance: bestServer.Distance,
+	return nil
+}
+
+// report notifies the progress callback that a phase has started
+func (r *Runner) report(state types.OutputState) {
+	if r.progress != nil {
+		r.progress(state, transfer.ProgressInfo{})
 	}
+}
 
-	return result, nil
+// serverInfo converts a server list entry into result server information
+func serverInfo(srv *types.Server) *types.ServerInfo {
+	return &types.ServerInfo{
+		ID:       srv.ID,
+		Host:     srv.Host,
+		Name:     srv.Name,
+		Country:  srv.Country,
+		Sponsor:  srv.Sponsor,
+		Distance: srv.Distance,
+	}
 }
 
 func parseCoordinate(f float64) (float64, float64) {
@@ -4,9 +4,17 @@ import (
 	"context"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/user/speed-test-go/internal/location"
+	"github.com/user/speed-test-go/internal/network"
+	"github.com/user/speed-test-go/internal/server"
+	"github.com/user/speed-test-go/internal/transfer"
 	"github.com/user/speed-test-go/pkg/types"
 )
 
@@ -465,3 +473,429 @@ func TestRunner_PingResult(t *testing.T) {
 		t.Errorf("Expected Latency 25.5, got: %f", pingResult.Latency)
 	}
 }
+
+func TestRunner_SetServerFilter(t *testing.T) {
+	r := NewRunner()
+	if !r.filter.IsEmpty() {
+		t.Error("Expected default filter to be empty")
+	}
+
+	r.SetServerFilter(server.Filter{Countries: []string{"US"}, MaxDistance: 500})
+
+	if len(r.filter.Countries) != 1 || r.filter.Countries[0] != "US" {
+		t.Errorf("Expected country filter [US], got: %v", r.filter.Countries)
+	}
+	if r.filter.MaxDistance != 500 {
+		t.Errorf("Expected max distance 500, got: %f", r.filter.MaxDistance)
+	}
+}
+
+func TestRunner_ComparisonTargets(t *testing.T) {
+	servers := []*types.Server{
+		{ID: "1", CC: "US", Distance: 10},
+		{ID: "2", CC: "DE", Distance: 20},
+		{ID: "3", CC: "US", Distance: 30},
+	}
+
+	t.Run("explicit IDs", func(t *testing.T) {
+		r := NewRunner()
+		r.SetCompareServers([]string{"3", "1"})
+
+		targets, err := r.comparisonTargets(servers)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(targets) != 2 || targets[0].ID != "3" || targets[1].ID != "1" {
+			t.Errorf("Expected servers [3 1], got: %v", targets)
+		}
+	})
+
+	t.Run("unknown ID", func(t *testing.T) {
+		r := NewRunner()
+		r.SetCompareServers([]string{"99"})
+
+		if _, err := r.comparisonTargets(servers); err == nil {
+			t.Error("Expected error for unknown server ID")
+		}
+	})
+
+	t.Run("top N after filter", func(t *testing.T) {
+		r := NewRunner()
+		r.SetCompareTop(5)
+		r.SetServerFilter(server.Filter{Countries: []string{"US"}})
+
+		targets, err := r.comparisonTargets(servers)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(targets) != 2 || targets[0].ID != "1" || targets[1].ID != "3" {
+			t.Errorf("Expected servers [1 3], got: %v", targets)
+		}
+	})
+}
+
+func TestRunner_IsComparison(t *testing.T) {
+	r := NewRunner()
+	if r.IsComparison() {
+		t.Error("Expected new runner not to be in comparison mode")
+	}
+
+	r.SetCompareTop(3)
+	if !r.IsComparison() {
+		t.Error("Expected runner with compare-top to be in comparison mode")
+	}
+}
+
+func TestRunner_DiscoverOffline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "servers.json")
+	data := `[
+  {"url": "http://far.internal/speedtest/upload.php", "lat": "48.85", "lon": "2.35", "id": "far", "cc": "FR"},
+  {"url": "http://near.internal/speedtest/upload.php", "lat": "52.37", "lon": "4.90", "id": "near", "cc": "NL"}
+]`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewRunner()
+	r.SetServersFile(path)
+	r.SetLocation(52.52, 13.40) // Berlin
+
+	result := &types.SpeedTestResult{}
+	servers, err := r.discover(context.Background(), result)
+	if err != nil {
+		t.Fatalf("discover() unexpected error: %v", err)
+	}
+
+	if len(servers) != 2 {
+		t.Fatalf("Expected 2 servers, got: %d", len(servers))
+	}
+	if servers[0].ID != "near" {
+		t.Errorf("Expected closest server first, got: %s", servers[0].ID)
+	}
+	if servers[0].Distance <= 0 || servers[0].Distance >= servers[1].Distance {
+		t.Errorf("Expected increasing distances, got: %f, %f", servers[0].Distance, servers[1].Distance)
+	}
+	if result.Cache != nil {
+		t.Error("Expected no cache info for offline discovery")
+	}
+}
+
+func TestRunner_DiscoverWithLocationProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "servers.json")
+	data := `[{"url": "http://st.internal/speedtest/upload.php", "lat": "52.37", "lon": "4.90", "id": "1"}]`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewRunner()
+	r.SetServersFile(path)
+	r.SetLocationProvider(location.Chain{&location.ManualProvider{Latitude: 52.37, Longitude: 4.90}})
+
+	servers, err := r.discover(context.Background(), &types.SpeedTestResult{})
+	if err != nil {
+		t.Fatalf("discover() unexpected error: %v", err)
+	}
+	if servers[0].Distance > 1 {
+		t.Errorf("Expected server at the provided location, got distance: %f", servers[0].Distance)
+	}
+}
+
+func TestRunner_SelectServerByID(t *testing.T) {
+	servers := []*types.Server{
+		{ID: "1", Distance: 10},
+		{ID: "2", Distance: 20},
+	}
+
+	r := NewRunner()
+	r.SetServerID("2")
+
+	srv, err := r.selectServer(context.Background(), servers)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if srv.ID != "2" {
+		t.Errorf("Expected server 2, got: %s", srv.ID)
+	}
+
+	r.SetServerID("99")
+	if _, err := r.selectServer(context.Background(), servers); err == nil {
+		t.Error("Expected error for unknown server ID")
+	}
+}
+
+// stubBackend is a Backend returning fixed servers and measurements
+type stubBackend struct {
+	servers []*types.Server
+	pinged  []string
+	samples bool
+}
+
+func (b *stubBackend) Name() string { return "stub" }
+
+func (b *stubBackend) SetSamples(enabled bool) { b.samples = enabled }
+
+func (b *stubBackend) Servers(ctx context.Context, factory *network.Factory) ([]*types.Server, error) {
+	return b.servers, nil
+}
+
+func (b *stubBackend) Ping(ctx context.Context, factory *network.Factory, srv *types.Server) (*types.PingResult, error) {
+	b.pinged = append(b.pinged, srv.ID)
+	return &types.PingResult{Latency: 10, Jitter: 1, Method: "stub", Samples: 1}, nil
+}
+
+func (b *stubBackend) Download(ctx context.Context, factory *network.Factory, srv *types.Server) (*types.TransferResult, error) {
+	return &types.TransferResult{Bandwidth: 1000, Bytes: 5000, Elapsed: 5000}, nil
+}
+
+func (b *stubBackend) Upload(ctx context.Context, factory *network.Factory, srv *types.Server) (*types.TransferResult, error) {
+	return &types.TransferResult{Bandwidth: 500, Bytes: 2500, Elapsed: 5000}, nil
+}
+
+// slowBackend is a stubBackend whose downloads from the slow server, or
+// over the slow address family, only end when their context does
+type slowBackend struct {
+	stubBackend
+	slowFamily network.Family
+}
+
+func (b *slowBackend) Download(ctx context.Context, factory *network.Factory, srv *types.Server) (*types.TransferResult, error) {
+	family := factory.Options().Family
+	if srv.ID == "slow" || (b.slowFamily != network.FamilyAny && family == b.slowFamily) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return b.stubBackend.Download(ctx, factory, srv)
+}
+
+// nearServers returns a single server close to the location of newStubRunner
+func nearServers() []*types.Server {
+	return []*types.Server{
+		{ID: "near", URL: "http://127.0.0.1:1/speedtest/upload.php", Lat: "52.37", Lon: "4.90"},
+	}
+}
+
+// newStubRunner returns a runner testing with backend from a fixed location
+func newStubRunner(t *testing.T, backend Backend) *Runner {
+	t.Helper()
+	r := NewRunner()
+	r.SetBackend(backend)
+	r.SetLocation(52.0, 4.9)
+	return r
+}
+
+func TestRunner_RunComparison_ServerTimeout(t *testing.T) {
+	backend := &slowBackend{stubBackend: stubBackend{servers: []*types.Server{
+		{ID: "slow", URL: "http://127.0.0.1:1/speedtest/upload.php", Lat: "52.37", Lon: "4.90"},
+		{ID: "fast", URL: "http://127.0.0.1:1/speedtest/upload.php", Lat: "52.38", Lon: "4.91"},
+	}}}
+
+	r := newStubRunner(t, backend)
+	r.SetCompareServers([]string{"slow", "fast"})
+	r.SetServerTimeout(50 * time.Millisecond)
+
+	comparison, err := r.RunComparison(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(comparison.Results) != 2 {
+		t.Fatalf("Expected 2 results, got: %d", len(comparison.Results))
+	}
+	if !strings.Contains(comparison.Results[0].Error, "deadline exceeded") {
+		t.Errorf("Expected the slow server to time out, got: %q", comparison.Results[0].Error)
+	}
+	if comparison.Results[1].Error != "" || comparison.Results[1].Download.Bytes != 5000 {
+		t.Errorf("Expected the fast server to get its own deadline, got: %+v", comparison.Results[1])
+	}
+
+	// Servers not reached before the run is cancelled are listed as failed
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r.SetServerTimeout(0)
+	comparison, err = r.RunComparison(ctx)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, result := range comparison.Results {
+		if result.Server == nil || result.Error == "" {
+			t.Errorf("Expected a failed result with its server, got: %+v", result)
+		}
+	}
+}
+
+func TestRunner_RunDualStack_FamilyTimeout(t *testing.T) {
+	backend := &slowBackend{
+		stubBackend: stubBackend{servers: nearServers()},
+		slowFamily:  network.FamilyIPv4,
+	}
+
+	r := newStubRunner(t, backend)
+	r.SetServerTimeout(50 * time.Millisecond)
+
+	comparison, err := r.RunDualStack(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(comparison.Results) != 2 {
+		t.Fatalf("Expected 2 results, got: %d", len(comparison.Results))
+	}
+	if v4 := comparison.Results[0]; v4.AddressFamily != "ipv4" || !strings.Contains(v4.Error, "deadline exceeded") {
+		t.Errorf("Expected the IPv4 pass to time out, got: %+v", v4)
+	}
+	if v6 := comparison.Results[1]; v6.AddressFamily != "ipv6" || v6.Error != "" || v6.Download.Bytes != 5000 {
+		t.Errorf("Expected the IPv6 pass to get its own deadline, got: %+v", v6)
+	}
+}
+
+func TestRunner_RunWithBackend(t *testing.T) {
+	backend := &stubBackend{servers: []*types.Server{
+		{ID: "far", URL: "http://127.0.0.1:1/speedtest/upload.php", Lat: "48.85", Lon: "2.35"},
+		{ID: "near", URL: "http://127.0.0.1:1/speedtest/upload.php", Lat: "52.37", Lon: "4.90"},
+	}}
+
+	r := newStubRunner(t, backend)
+
+	result, err := r.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Without a ServerSelector the closest server is used
+	if result.Server == nil || result.Server.ID != "near" {
+		t.Errorf("Expected closest server near, got: %+v", result.Server)
+	}
+	if len(backend.pinged) != 1 || backend.pinged[0] != "near" {
+		t.Errorf("Expected only the selected server to be pinged, got: %v", backend.pinged)
+	}
+	if result.Ping.Method != "stub" || result.Download.Bytes != 5000 || result.Upload.Bytes != 2500 {
+		t.Errorf("Expected backend measurements in result, got: %+v", result)
+	}
+}
+
+func TestRunner_Samples(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		backend := &stubBackend{servers: nearServers()}
+
+		r := newStubRunner(t, backend)
+		r.SetSamples(enabled)
+
+		if _, err := r.Run(context.Background()); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if backend.samples != enabled {
+			t.Errorf("SetSamples(%v): backend recording samples = %v", enabled, backend.samples)
+		}
+	}
+}
+
+func TestRunner_Progress(t *testing.T) {
+	backend := &stubBackend{servers: nearServers()}
+
+	r := newStubRunner(t, backend)
+
+	var states []types.OutputState
+	r.SetProgress(func(state types.OutputState, info transfer.ProgressInfo) {
+		states = append(states, state)
+	})
+
+	if _, err := r.Run(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := []types.OutputState{types.StatePing, types.StateDownload, types.StateUpload, types.StateDone}
+	if !reflect.DeepEqual(states, want) {
+		t.Errorf("Expected phases %v, got %v", want, states)
+	}
+}
+
+func TestRunner_TransferBytes(t *testing.T) {
+	backend := &stubBackend{servers: nearServers()}
+
+	r := newStubRunner(t, backend)
+	r.SetTransferBytes(500_000_000, 0)
+
+	// The stub backend only runs timed transfers
+	_, err := r.Run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "does not support fixed-size transfers") {
+		t.Errorf("Expected an unsupported backend error, got: %v", err)
+	}
+
+	sized := &SpeedtestBackend{protocol: ProtocolHTTP}
+	r.SetBackend(sized)
+	r.SetTransferBytes(500_000_000, 100_000_000)
+	if err := r.runServer(context.Background(), network.DefaultFactory(), &types.SpeedTestResult{}, backend.servers[0]); err == nil {
+		t.Fatal("Expected the unreachable server to fail")
+	}
+	if sized.downloadBytes != 500_000_000 || sized.uploadBytes != 100_000_000 {
+		t.Errorf("Expected the sizes to reach the backend, got %d and %d", sized.downloadBytes, sized.uploadBytes)
+	}
+}
+
+// cappedBackend is a stubBackend whose transfers use up a data cap
+type cappedBackend struct {
+	stubBackend
+	dataCap *transfer.DataCap
+}
+
+func (b *cappedBackend) SetDataCap(limit *transfer.DataCap) { b.dataCap = limit }
+
+func (b *cappedBackend) Download(ctx context.Context, factory *network.Factory, srv *types.Server) (*types.TransferResult, error) {
+	return &types.TransferResult{Bandwidth: 1000, Bytes: b.dataCap.Claim(5000)}, nil
+}
+
+func (b *cappedBackend) Upload(ctx context.Context, factory *network.Factory, srv *types.Server) (*types.TransferResult, error) {
+	return &types.TransferResult{Bandwidth: 500, Bytes: b.dataCap.Claim(2500)}, nil
+}
+
+func TestRunner_MaxData(t *testing.T) {
+	tests := []struct {
+		limit     int64
+		download  int64
+		upload    int64
+		truncated bool
+	}{
+		{0, 5000, 2500, false},
+		{10000, 5000, 2500, false},
+		{6000, 5000, 1000, true},
+		{4000, 4000, 0, true},
+	}
+
+	for _, tt := range tests {
+		r := newStubRunner(t, &cappedBackend{stubBackend: stubBackend{servers: nearServers()}})
+		r.SetMaxData(tt.limit)
+
+		result, err := r.Run(context.Background())
+		if err != nil {
+			t.Fatalf("limit %d: unexpected error: %v", tt.limit, err)
+		}
+		if result.Download.Bytes != tt.download || result.Upload.Bytes != tt.upload {
+			t.Errorf("limit %d: expected %d down and %d up, got %d and %d", tt.limit, tt.download, tt.upload, result.Download.Bytes, result.Upload.Bytes)
+		}
+		if result.DataUsed != tt.download+tt.upload || result.Truncated != tt.truncated {
+			t.Errorf("limit %d: expected %d bytes used, truncated %v; got %d, %v", tt.limit, tt.download+tt.upload, tt.truncated, result.DataUsed, result.Truncated)
+		}
+	}
+
+	// Backends that cannot stop their transfers are rejected
+	r := newStubRunner(t, &stubBackend{servers: nearServers()})
+	r.SetMaxData(1000)
+	if _, err := r.Run(context.Background()); err == nil || !strings.Contains(err.Error(), "does not support data caps") {
+		t.Errorf("Expected an unsupported backend error, got: %v", err)
+	}
+}
+
+func TestNewSpeedtestBackend(t *testing.T) {
+	for _, protocol := range []string{ProtocolHTTP, ProtocolTCP} {
+		b, err := NewSpeedtestBackend(protocol)
+		if err != nil {
+			t.Errorf("NewSpeedtestBackend(%s) unexpected error: %v", protocol, err)
+			continue
+		}
+		if b.Protocol() != protocol || b.Name() != "speedtest" {
+			t.Errorf("Unexpected backend: %s/%s", b.Name(), b.Protocol())
+		}
+	}
+
+	if _, err := NewSpeedtestBackend("quic"); err == nil {
+		t.Error("Expected error for unknown protocol")
+	}
+}
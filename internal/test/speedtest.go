@@ -0,0 +1,219 @@
+package test
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/user/speed-test-go/internal/network"
+	"github.com/user/speed-test-go/internal/server"
+	"github.com/user/speed-test-go/internal/transfer"
+	"github.com/user/speed-test-go/pkg/types"
+)
+
+// Transfer protocols supported by the speedtest.net backend
+const (
+	ProtocolHTTP = "http"
+	ProtocolTCP  = "tcp"
+)
+
+// SpeedtestBackend measures speedtest.net servers over HTTP or their
+// line-based TCP protocol
+type SpeedtestBackend struct {
+	protocol string
+	fallback bool
+	samples  bool
+	progress ProgressFunc
+
+	// Fixed-size transfers, 0 when timed
+	downloadBytes int64
+	uploadBytes   int64
+	dataCap       *transfer.DataCap
+}
+
+// NewSpeedtestBackend creates a speedtest.net backend using protocol (http or tcp)
+func NewSpeedtestBackend(protocol string) (*SpeedtestBackend, error) {
+	switch protocol {
+	case ProtocolHTTP, ProtocolTCP:
+		return &SpeedtestBackend{protocol: protocol}, nil
+	default:
+		return nil, fmt.Errorf("unknown protocol %q (use http or tcp)", protocol)
+	}
+}
+
+// Name returns the backend name
+func (b *SpeedtestBackend) Name() string {
+	return "speedtest"
+}
+
+// Protocol returns the transfer protocol in use
+func (b *SpeedtestBackend) Protocol() string {
+	return b.protocol
+}
+
+// SetFallback enables public fallback endpoints for HTTP transfers, used only
+// once the server's own transfer URLs fail
+func (b *SpeedtestBackend) SetFallback(enabled bool) {
+	b.fallback = enabled
+}
+
+// SetSamples enables recording latency and throughput time series
+func (b *SpeedtestBackend) SetSamples(enabled bool) {
+	b.samples = enabled
+}
+
+// SetTransferBytes makes the download and upload transfer exactly these
+// many bytes instead of running for a fixed duration; 0 keeps a phase timed
+func (b *SpeedtestBackend) SetTransferBytes(download, upload int64) {
+	b.downloadBytes, b.uploadBytes = download, upload
+}
+
+// SetDataCap stops transfers once limit has been used up
+func (b *SpeedtestBackend) SetDataCap(limit *transfer.DataCap) {
+	b.dataCap = limit
+}
+
+// SetProgress reports the progress of transfers to fn
+func (b *SpeedtestBackend) SetProgress(fn ProgressFunc) {
+	b.progress = fn
+}
+
+// report returns the progress callback for a transfer in state, nil when
+// progress is not reported
+func (b *SpeedtestBackend) report(state types.OutputState) func(transfer.ProgressInfo) {
+	if b.progress == nil {
+		return nil
+	}
+	return func(info transfer.ProgressInfo) {
+		b.progress(state, info)
+	}
+}
+
+// Servers fetches the speedtest.net server list
+func (b *SpeedtestBackend) Servers(ctx context.Context, factory *network.Factory) ([]*types.Server, error) {
+	return server.FetchServerList(ctx, factory.Client(server.DiscoveryTimeout))
+}
+
+// SelectServer pings up to n candidates over HTTP and returns the fastest
+func (b *SpeedtestBackend) SelectServer(ctx context.Context, factory *network.Factory, candidates []*types.Server, n int) (*types.Server, error) {
+	return server.SelectBestServerByPing(ctx, factory.Client(server.PingTimeout), candidates, n)
+}
+
+// Ping measures latency over WebSocket or HTTP, or with TCP PING commands
+func (b *SpeedtestBackend) Ping(ctx context.Context, factory *network.Factory, srv *types.Server) (*types.PingResult, error) {
+	var series *transfer.LatencySeries
+	if b.samples {
+		series = transfer.NewLatencySeries()
+	}
+
+	if b.protocol == ProtocolTCP {
+		tt := b.tcpTest(factory)
+		tt.SetLatencySeries(series)
+		latencies, err := tt.Ping(ctx, server.GetServerTCPAddr(srv))
+		if err != nil {
+			return nil, err
+		}
+		latency, jitter := CalculateLatency(latencies)
+		return &types.PingResult{
+			Latency: latency,
+			Jitter:  jitter,
+			Method:  ProtocolTCP,
+			Samples: len(latencies),
+			Series:  series.Samples(),
+		}, nil
+	}
+
+	pt := NewPingTest()
+	pt.SetClient(factory.HTTPClient())
+	pt.SetLatencySeries(series)
+	return pt.Measure(ctx, server.GetServerBaseURL(srv))
+}
+
+// tcpTest creates a TCP protocol test dialing through factory
+func (b *SpeedtestBackend) tcpTest(factory *network.Factory) *transfer.TCPTest {
+	tt := transfer.NewTCPTest(factory.DialContext)
+	tt.SetSamples(b.samples)
+	tt.SetDataCap(b.dataCap)
+	return tt
+}
+
+// Download measures download throughput
+func (b *SpeedtestBackend) Download(ctx context.Context, factory *network.Factory, srv *types.Server) (*types.TransferResult, error) {
+	var result *transfer.DownloadResult
+	var err error
+	if b.protocol == ProtocolTCP {
+		tt := b.tcpTest(factory)
+		tt.SetTargetBytes(b.downloadBytes)
+		tt.SetProgress(b.report(types.StateDownload))
+		result, err = tt.Download(ctx, server.GetServerTCPAddr(srv))
+	} else {
+		dt := transfer.NewDownloadTest()
+		dt.SetClient(factory.DownloadClient())
+		dt.SetSamples(b.samples)
+		dt.SetTargetBytes(b.downloadBytes)
+		dt.SetDataCap(b.dataCap)
+		dt.SetProgress(b.report(types.StateDownload))
+		if b.fallback {
+			dt.SetFallbackURLs(transfer.DefaultDownloadFallbackURLs)
+		}
+		result, err = dt.Measure(ctx, server.GetServerBaseURL(srv))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.TransferResult{
+		Bandwidth:      result.Bandwidth,
+		Bytes:          result.Bytes,
+		Elapsed:        result.Elapsed.Milliseconds(),
+		Protocol:       result.Protocol,
+		TargetBytes:    b.downloadBytes,
+		URLs:           result.URLs,
+		Attempts:       result.URLAttempts,
+		FailedAttempts: result.FailedAttempts,
+		StatusCodes:    result.StatusCodes,
+		Errors:         result.Errors,
+		Streams:        result.Streams,
+		Series:         result.Series,
+	}, nil
+}
+
+// Upload measures upload throughput
+func (b *SpeedtestBackend) Upload(ctx context.Context, factory *network.Factory, srv *types.Server) (*types.TransferResult, error) {
+	var result *transfer.UploadResult
+	var err error
+	if b.protocol == ProtocolTCP {
+		tt := b.tcpTest(factory)
+		tt.SetTargetBytes(b.uploadBytes)
+		tt.SetProgress(b.report(types.StateUpload))
+		result, err = tt.Upload(ctx, server.GetServerTCPAddr(srv))
+	} else {
+		ut := transfer.NewUploadTest()
+		ut.SetClient(factory.UploadClient())
+		ut.SetSamples(b.samples)
+		ut.SetTargetBytes(b.uploadBytes)
+		ut.SetDataCap(b.dataCap)
+		ut.SetProgress(b.report(types.StateUpload))
+		if b.fallback {
+			ut.SetFallbackURLs(transfer.DefaultUploadFallbackURLs)
+		}
+		result, err = ut.Measure(ctx, server.GetServerBaseURL(srv))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.TransferResult{
+		Bandwidth:      result.Bandwidth,
+		Bytes:          result.Bytes,
+		Elapsed:        result.Elapsed.Milliseconds(),
+		Protocol:       result.Protocol,
+		TargetBytes:    b.uploadBytes,
+		URLs:           result.URLs,
+		Attempts:       result.URLAttempts,
+		FailedAttempts: result.FailedAttempts,
+		StatusCodes:    result.StatusCodes,
+		Errors:         result.Errors,
+		Streams:        result.Streams,
+		Series:         result.Series,
+	}, nil
+}
@@ -0,0 +1,243 @@
+package test
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/user/speed-test-go/internal/transfer"
+)
+
+// websocketGUID is appended to the handshake key to derive the accept value (RFC 6455)
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket frame opcodes
+const (
+	opText  = 0x1
+	opClose = 0x8
+	opPing  = 0x9
+	opPong  = 0xa
+)
+
+// maxFramePayload bounds frames read from the server; PONG replies are tiny
+const maxFramePayload = 64 * 1024
+
+// WebSocketPing measures latency with PING/PONG round trips over a single
+// WebSocket connection, avoiding the per-request overhead of HTTP pings
+type WebSocketPing struct {
+	client   *http.Client
+	numPings int
+	timeout  time.Duration
+	series   *transfer.LatencySeries
+}
+
+// NewWebSocketPing creates a WebSocket latency probe using client for the handshake
+func NewWebSocketPing(client *http.Client) *WebSocketPing {
+	return &WebSocketPing{
+		client:   client,
+		numPings: 20,
+		timeout:  10 * time.Second,
+	}
+}
+
+// SetLatencySeries records every round trip in series
+func (wp *WebSocketPing) SetLatencySeries(series *transfer.LatencySeries) {
+	wp.series = series
+}
+
+// Run opens a WebSocket to the server's /ws endpoint and returns the round
+// trip time of each PING
+func (wp *WebSocketPing) Run(ctx context.Context, serverURL string) ([]time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, wp.timeout)
+	defer cancel()
+
+	conn, err := wp.dial(ctx, serverURL+"/ws")
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// Unblock reads and writes once the context ends
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	reader := bufio.NewReader(conn)
+	latencies := make([]time.Duration, 0, wp.numPings)
+	for i := 0; i < wp.numPings; i++ {
+		start := time.Now()
+		msg := "PING " + strconv.FormatInt(start.UnixMilli(), 10)
+		if err := writeFrame(conn, opText, []byte(msg)); err != nil {
+			return latencies, fmt.Errorf("failed to send ping: %w", err)
+		}
+
+		if err := readPong(reader, conn); err != nil {
+			return latencies, err
+		}
+		latency := time.Since(start)
+		latencies = append(latencies, latency)
+		wp.series.Add(latency)
+	}
+
+	writeFrame(conn, opClose, nil)
+	return latencies, nil
+}
+
+// dial performs the WebSocket opening handshake and returns the upgraded connection
+func (wp *WebSocketPing) dial(ctx context.Context, url string) (io.ReadWriteCloser, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate handshake key: %w", err)
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", key)
+
+	// A client timeout wraps the body so it is no longer writable; the
+	// context bounds the probe instead
+	client := *wp.client
+	client.Timeout = 0
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("websocket handshake failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		resp.Body.Close()
+		return nil, fmt.Errorf("websocket not supported: server returned status %d", resp.StatusCode)
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		resp.Body.Close()
+		return nil, fmt.Errorf("websocket handshake failed: invalid accept key")
+	}
+
+	// The body of a 101 response is the upgraded connection
+	conn, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok {
+		resp.Body.Close()
+		return nil, fmt.Errorf("websocket handshake failed: connection is not writable")
+	}
+	return conn, nil
+}
+
+// readPong reads frames until a PONG text message arrives, answering control frames
+func readPong(r *bufio.Reader, w io.Writer) error {
+	for {
+		opcode, payload, err := readFrame(r)
+		if err != nil {
+			return fmt.Errorf("failed to read pong: %w", err)
+		}
+
+		switch opcode {
+		case opText:
+			if strings.HasPrefix(string(payload), "PONG") {
+				return nil
+			}
+		case opPing:
+			if err := writeFrame(w, opPong, payload); err != nil {
+				return fmt.Errorf("failed to answer ping: %w", err)
+			}
+		case opClose:
+			return fmt.Errorf("server closed the websocket")
+		}
+	}
+}
+
+// acceptKey returns the Sec-WebSocket-Accept value expected for key
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// writeFrame writes a single masked frame, as required for client frames
+func writeFrame(w io.Writer, opcode byte, payload []byte) error {
+	header := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, 0x80|byte(n))
+	case n <= 0xffff:
+		header = append(header, 0x80|126)
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header = append(header, 0x80|127)
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	var mask [4]byte
+	if _, err := rand.Read(mask[:]); err != nil {
+		return err
+	}
+	header = append(header, mask[:]...)
+
+	masked := make([]byte, len(payload))
+	for i, b := range payload {
+		masked[i] = b ^ mask[i%4]
+	}
+
+	_, err := w.Write(append(header, masked...))
+	return err
+}
+
+// readFrame reads a single frame. Fragmented messages are not expected for
+// PING/PONG and are returned frame by frame.
+func readFrame(r io.Reader) (byte, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return 0, nil, err
+	}
+	opcode := head[0] & 0x0f
+	masked := head[1]&0x80 != 0
+
+	length := uint64(head[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > maxFramePayload {
+		return 0, nil, fmt.Errorf("frame too large (%d bytes)", length)
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(r, mask[:]); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+
+	return opcode, payload, nil
+}
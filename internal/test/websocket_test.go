@@ -0,0 +1,202 @@
+package test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/user/speed-test-go/internal/network"
+)
+
+// newWebSocketServer starts a server answering WebSocket PING messages on
+// /ws and latency.txt requests, counting the pings it receives
+func newWebSocketServer(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var pings atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "websocket" {
+			http.Error(w, "upgrade required", http.StatusUpgradeRequired)
+			return
+		}
+
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("Hijack failed: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+			"Upgrade: websocket\r\n" +
+			"Connection: Upgrade\r\n" +
+			"Sec-WebSocket-Accept: " + acceptKey(r.Header.Get("Sec-WebSocket-Key")) + "\r\n\r\n")
+		rw.Flush()
+
+		for {
+			opcode, payload, err := readFrame(rw)
+			if err != nil || opcode == opClose {
+				return
+			}
+			msg := string(payload)
+			if !strings.HasPrefix(msg, "PING") {
+				continue
+			}
+			pings.Add(1)
+
+			// Server frames are sent unmasked
+			reply := "PONG" + strings.TrimPrefix(msg, "PING")
+			rw.Write(append([]byte{0x80 | opText, byte(len(reply))}, reply...))
+			rw.Flush()
+		}
+	})
+	mux.HandleFunc("/speedtest/latency.txt", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("test=test"))
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server, &pings
+}
+
+func TestWebSocketPing_Run(t *testing.T) {
+	server, pings := newWebSocketServer(t)
+
+	wp := NewWebSocketPing(http.DefaultClient)
+	latencies, err := wp.Run(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(latencies) != wp.numPings {
+		t.Errorf("Expected %d latencies, got: %d", wp.numPings, len(latencies))
+	}
+	if int(pings.Load()) != wp.numPings {
+		t.Errorf("Expected %d pings on one connection, got: %d", wp.numPings, pings.Load())
+	}
+	for _, l := range latencies {
+		if l <= 0 {
+			t.Errorf("Expected positive latency, got: %v", l)
+		}
+	}
+}
+
+func TestWebSocketPing_Unsupported(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	if _, err := NewWebSocketPing(http.DefaultClient).Run(context.Background(), server.URL); err == nil {
+		t.Error("Expected error for server without WebSocket support")
+	}
+}
+
+func TestWebSocketPing_Proxy(t *testing.T) {
+	t.Setenv("NO_PROXY", "")
+
+	// The handshake must reach the proxy rather than the server directly
+	handshakes := make(chan string, 1)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handshakes <- r.URL.Host + r.URL.Path + " " + r.Header.Get("Upgrade")
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer proxy.Close()
+
+	factory, err := network.NewFactory(network.Options{Proxy: proxy.URL})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if _, err := NewWebSocketPing(factory.HTTPClient()).Run(context.Background(), "http://speedtest.invalid"); err == nil {
+		t.Error("Expected the proxy's error response to fail the probe")
+	}
+	if got := <-handshakes; got != "speedtest.invalid/ws websocket" {
+		t.Errorf("Expected the handshake to go through the proxy, got: %q", got)
+	}
+}
+
+func TestPingTest_Measure_WebSocket(t *testing.T) {
+	server, _ := newWebSocketServer(t)
+
+	result, err := NewPingTest().Measure(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Method != "websocket" {
+		t.Errorf("Expected websocket method, got: %s", result.Method)
+	}
+	if result.Samples != 20 {
+		t.Errorf("Expected 20 samples, got: %d", result.Samples)
+	}
+}
+
+func TestPingTest_Measure_FallbackToHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	result, err := NewPingTest().Measure(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Method != "http" {
+		t.Errorf("Expected fallback to http, got: %s", result.Method)
+	}
+	if result.Samples != 5 {
+		t.Errorf("Expected 5 samples, got: %d", result.Samples)
+	}
+}
+
+func TestPingTest_Measure_WebSocketDisabled(t *testing.T) {
+	server, pings := newWebSocketServer(t)
+
+	pt := NewPingTest()
+	pt.SetWebSocket(false)
+	result, err := pt.Measure(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Method != "http" || pings.Load() != 0 {
+		t.Errorf("Expected only HTTP pings, got method %s and %d websocket pings", result.Method, pings.Load())
+	}
+}
+
+func TestWebSocketFrame_RoundTrip(t *testing.T) {
+	for _, size := range []int{0, 5, 125, 126, 300, 70000} {
+		payload := bytes.Repeat([]byte{'x'}, size)
+
+		var buf bytes.Buffer
+		if err := writeFrame(&buf, opText, payload); err != nil {
+			t.Fatalf("writeFrame(%d bytes) failed: %v", size, err)
+		}
+
+		opcode, got, err := readFrame(bufio.NewReader(&buf))
+		if size > maxFramePayload {
+			if err == nil {
+				t.Errorf("Expected error for %d byte frame", size)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("readFrame(%d bytes) failed: %v", size, err)
+		}
+		if opcode != opText || !bytes.Equal(got, payload) {
+			t.Errorf("Round trip of %d bytes returned opcode %d and %d bytes", size, opcode, len(got))
+		}
+	}
+}
+
+func TestAcceptKey(t *testing.T) {
+	// Example from RFC 6455 section 1.3
+	if got := acceptKey("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("acceptKey() = %s", got)
+	}
+}
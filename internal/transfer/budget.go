@@ -0,0 +1,172 @@
+package transfer
+
+import (
+	"context"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// byteBudget shares a fixed number of bytes between the workers of a phase.
+// Workers claim bytes before transferring them and release what they did not
+// use, so together they transfer exactly the budget. Claims are also limited
+// by the parent budget, if any. A nil budget is unlimited.
+type byteBudget struct {
+	remaining atomic.Int64
+	parent    *byteBudget
+}
+
+// newByteBudget returns a budget of n bytes, or nil (unlimited) if n <= 0
+func newByteBudget(n int64) *byteBudget {
+	if n <= 0 {
+		return nil
+	}
+	b := &byteBudget{}
+	b.remaining.Store(n)
+	return b
+}
+
+// Claim reserves up to n bytes and returns how many were reserved, 0 once
+// the budget is spent
+func (b *byteBudget) Claim(n int64) int64 {
+	if b == nil {
+		return n
+	}
+	for {
+		remaining := b.remaining.Load()
+		if remaining <= 0 {
+			return 0
+		}
+		claim := min(n, remaining)
+		if !b.remaining.CompareAndSwap(remaining, remaining-claim) {
+			continue
+		}
+
+		// Give back what the parent refuses
+		if granted := b.parent.Claim(claim); granted < claim {
+			b.remaining.Add(claim - granted)
+			claim = granted
+		}
+		return claim
+	}
+}
+
+// Release returns n claimed bytes that were not transferred
+func (b *byteBudget) Release(n int64) {
+	if b != nil && n > 0 {
+		b.remaining.Add(n)
+		b.parent.Release(n)
+	}
+}
+
+// Refill returns n transferred bytes that have to be transferred again, e.g.
+// the part of a failed upload. Unlike Release it leaves them spent in the
+// parent budget, which limits the data that actually crossed the network.
+func (b *byteBudget) Refill(n int64) {
+	if b != nil && n > 0 {
+		b.remaining.Add(n)
+	}
+}
+
+// Available returns the bytes left to claim, which may also be limited by
+// the parent budget
+func (b *byteBudget) Available() int64 {
+	if b == nil {
+		return math.MaxInt64
+	}
+	return max(min(b.remaining.Load(), b.parent.Available()), 0)
+}
+
+// Spent reports whether every byte of the budget, or its parent, has been claimed
+func (b *byteBudget) Spent() bool {
+	return b != nil && (b.remaining.Load() <= 0 || b.parent.Spent())
+}
+
+// DataCap limits the bytes transferred by several phases together, e.g. every
+// phase of a run over a metered link. A nil cap is unlimited.
+type DataCap struct {
+	limit  int64
+	budget *byteBudget
+}
+
+// NewDataCap creates a cap of limit bytes
+func NewDataCap(limit int64) *DataCap {
+	return &DataCap{limit: limit, budget: newByteBudget(limit)}
+}
+
+// Claim reserves up to n bytes and returns how many were reserved, 0 once
+// the cap is reached. Bytes that end up not being transferred must be
+// released.
+func (c *DataCap) Claim(n int64) int64 {
+	if c == nil {
+		return n
+	}
+	return c.budget.Claim(n)
+}
+
+// Release returns n claimed bytes that were not transferred
+func (c *DataCap) Release(n int64) {
+	if c != nil {
+		c.budget.Release(n)
+	}
+}
+
+// Reached reports whether the cap has been used up
+func (c *DataCap) Reached() bool {
+	return c != nil && c.budget.Spent()
+}
+
+// Used returns the bytes claimed so far
+func (c *DataCap) Used() int64 {
+	if c == nil || c.budget == nil {
+		return 0
+	}
+	return c.limit - c.budget.remaining.Load()
+}
+
+// phaseBudget returns the budget of a phase transferring target bytes, 0 for
+// a timed phase, within limit. It is nil when neither limits the phase.
+func phaseBudget(target int64, limit *DataCap) *byteBudget {
+	var parent *byteBudget
+	if limit != nil {
+		parent = limit.budget
+	}
+	b := newByteBudget(target)
+	if b == nil {
+		return parent
+	}
+	b.parent = parent
+	return b
+}
+
+// phaseContext bounds a phase by its test duration, or only by ctx when it
+// transfers target bytes
+func phaseContext(ctx context.Context, duration time.Duration, target int64) (context.Context, context.CancelFunc) {
+	if target > 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, duration)
+}
+
+// phaseBandwidth returns the bandwidth to report for phase. A phase
+// transferring target bytes reports its average over elapsed rather than the
+// smoothed rate, which lags behind a transfer that is over in a few seconds.
+func phaseBandwidth(phase *phaseResult, target int64, elapsed time.Duration) int64 {
+	if target > 0 && elapsed > 0 {
+		return int64(float64(phase.bytes) / elapsed.Seconds())
+	}
+	return int64(phase.rate)
+}
+
+// budgetProgress estimates how far a phase transferring target bytes has got
+// from the bytes transferred so far and the current rate
+func budgetProgress(total, target int64, rate float64) (float64, time.Duration) {
+	if total >= target {
+		return 1, 0
+	}
+	var eta time.Duration
+	if rate > 0 {
+		eta = time.Duration(float64(target-total) / rate * float64(time.Second))
+	}
+	return float64(total) / float64(target), eta
+}
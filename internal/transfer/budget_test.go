@@ -0,0 +1,187 @@
+package transfer
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestByteBudget(t *testing.T) {
+	b := newByteBudget(100)
+
+	if got := b.Claim(60); got != 60 {
+		t.Errorf("Expected to claim 60 bytes, got %d", got)
+	}
+	if got := b.Claim(60); got != 40 {
+		t.Errorf("Expected to claim the remaining 40 bytes, got %d", got)
+	}
+	if !b.Spent() {
+		t.Error("Expected the budget to be spent")
+	}
+	if got := b.Claim(60); got != 0 {
+		t.Errorf("Expected nothing left to claim, got %d", got)
+	}
+
+	b.Release(25)
+	if b.Spent() {
+		t.Error("Expected released bytes to be available again")
+	}
+	if got := b.Claim(60); got != 25 {
+		t.Errorf("Expected to claim the 25 released bytes, got %d", got)
+	}
+}
+
+func TestByteBudget_Unlimited(t *testing.T) {
+	b := newByteBudget(0)
+	if b != nil {
+		t.Fatalf("Expected no budget for 0 bytes, got %+v", b)
+	}
+	if got := b.Claim(1 << 20); got != 1<<20 {
+		t.Errorf("Expected an unlimited claim, got %d", got)
+	}
+	b.Release(10)
+	if b.Spent() {
+		t.Error("Expected an unlimited budget never to be spent")
+	}
+}
+
+func TestByteBudget_Concurrent(t *testing.T) {
+	const total = 1_000_003
+	b := newByteBudget(total)
+
+	var mu sync.Mutex
+	var claimed int64
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				n := b.Claim(1000)
+				if n == 0 {
+					return
+				}
+				mu.Lock()
+				claimed += n
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if claimed != total {
+		t.Errorf("Expected workers to claim exactly %d bytes, got %d", total, claimed)
+	}
+}
+
+func TestByteBudget_Parent(t *testing.T) {
+	limit := NewDataCap(100)
+	b := phaseBudget(80, limit)
+
+	if got := b.Claim(60); got != 60 {
+		t.Errorf("Expected to claim 60 bytes, got %d", got)
+	}
+	if got := b.Available(); got != 20 {
+		t.Errorf("Expected 20 bytes left of the target, got %d", got)
+	}
+	// The next phase shares the cap but not the first phase's target
+	other := phaseBudget(0, limit)
+	if got := other.Claim(60); got != 40 {
+		t.Errorf("Expected the cap to limit the claim to 40 bytes, got %d", got)
+	}
+	if got := b.Available(); got != 0 {
+		t.Errorf("Expected the cap to leave nothing available, got %d", got)
+	}
+	if !b.Spent() || !limit.Reached() {
+		t.Error("Expected the cap to be reached")
+	}
+	if got := b.Claim(20); got != 0 {
+		t.Errorf("Expected nothing left under the cap, got %d", got)
+	}
+
+	// Bytes refused by the cap remain in the phase budget
+	other.Release(30)
+	if got := b.Claim(50); got != 20 {
+		t.Errorf("Expected the remaining 20 bytes of the target, got %d", got)
+	}
+	if used := limit.Used(); used != 90 {
+		t.Errorf("Expected 90 bytes used, got %d", used)
+	}
+}
+
+func TestByteBudget_Refill(t *testing.T) {
+	limit := NewDataCap(100)
+	b := phaseBudget(50, limit)
+
+	if got := b.Claim(50); got != 50 {
+		t.Errorf("Expected to claim 50 bytes, got %d", got)
+	}
+	// 30 bytes were sent before the transfer failed
+	b.Release(20)
+	b.Refill(30)
+
+	if got := b.Available(); got != 50 {
+		t.Errorf("Expected the whole target to be available again, got %d", got)
+	}
+	if used := limit.Used(); used != 30 {
+		t.Errorf("Expected the 30 bytes sent to stay used, got %d", used)
+	}
+}
+
+func TestDataCap_Nil(t *testing.T) {
+	var limit *DataCap
+	if got := limit.Claim(1000); got != 1000 {
+		t.Errorf("Expected an unlimited claim, got %d", got)
+	}
+	limit.Release(1000)
+	if limit.Reached() || limit.Used() != 0 {
+		t.Error("Expected a nil cap never to be reached")
+	}
+	if b := phaseBudget(0, nil); b != nil {
+		t.Errorf("Expected no budget for an uncapped timed phase, got %+v", b)
+	}
+}
+
+func TestBudgetProgress(t *testing.T) {
+	tests := []struct {
+		total, target int64
+		rate          float64
+		progress      float64
+		eta           time.Duration
+	}{
+		{0, 1000, 0, 0, 0},
+		{250, 1000, 100, 0.25, 7500 * time.Millisecond},
+		{1000, 1000, 100, 1, 0},
+		{1200, 1000, 100, 1, 0},
+	}
+
+	for _, tt := range tests {
+		progress, eta := budgetProgress(tt.total, tt.target, tt.rate)
+		if progress != tt.progress || eta != tt.eta {
+			t.Errorf("budgetProgress(%d, %d, %v) = %f, %v; want %f, %v", tt.total, tt.target, tt.rate, progress, eta, tt.progress, tt.eta)
+		}
+	}
+}
+
+func TestPhaseBandwidth(t *testing.T) {
+	phase := &phaseResult{bytes: 10_000_000, rate: 1_000_000}
+
+	tests := []struct {
+		name    string
+		target  int64
+		elapsed time.Duration
+		want    int64
+	}{
+		{"timed", 0, 2 * time.Second, 1_000_000},
+		{"fixed size", 10_000_000, 2 * time.Second, 5_000_000},
+		{"fixed size without elapsed time", 10_000_000, 0, 1_000_000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := phaseBandwidth(phase, tt.target, tt.elapsed); got != tt.want {
+				t.Errorf("Expected bandwidth %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
@@ -5,10 +5,10 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"sync"
 	"time"
 
 	"github.com/user/speed-test-go/internal/network"
+	"github.com/user/speed-test-go/pkg/types"
 )
 
 // DownloadTest performs download speed testing
@@ -17,23 +17,27 @@ type DownloadTest struct {
 	numThreads   int
 	testDuration time.Duration
 	captureFreq  time.Duration
+	fallbackURLs []string
+	samples      bool
+	targetBytes  int64
+	dataCap      *DataCap
+	onProgress   func(ProgressInfo)
 }
 
 // NewDownloadTest creates a new download test instance
 func NewDownloadTest() *DownloadTest {
 	return &DownloadTest{
-		client:       network.DownloadClient(),
+		client:       network.DefaultFactory().DownloadClient(),
 		numThreads:   4,
 		testDuration: 15 * time.Second,
 		captureFreq:  100 * time.Millisecond,
 	}
 }
 
-// Fallback test file URLs when speedtest.net servers don't support full protocol
-// Prioritized by geographic proximity to Indonesia for better speeds
-var downloadTestURLs = []string{
+// DefaultDownloadFallbackURLs are public test files used when the server's own
+// download URLs fail and fallbacks are enabled with SetFallbackURLs
+var DefaultDownloadFallbackURLs = []string{
 	// Global CDN with good coverage (tested and working)
-	"https://speed.cloudflare.com/__down?bytes=1000000",
 	"https://dl.google.com/dl/testbed/testfile1000k.bin",
 	"https://speed-test-bucket.s3.amazonaws.com/test100mb.bin",
 	// Singapore CDN (closest to Indonesia)
@@ -47,159 +51,190 @@ var downloadTestURLs = []string{
 	"http://speed.hetzner.de/1MB.bin",
 }
 
+// SetClient sets the HTTP client used for downloads
+func (dt *DownloadTest) SetClient(client *http.Client) {
+	dt.client = client
+}
+
+// SetFallbackURLs sets the URLs a thread switches to once all of the server's
+// URLs have failed in a row. Fallbacks are disabled by default.
+func (dt *DownloadTest) SetFallbackURLs(urls []string) {
+	dt.fallbackURLs = urls
+}
+
+// SetSamples enables recording a throughput time series at captureFreq
+func (dt *DownloadTest) SetSamples(enabled bool) {
+	dt.samples = enabled
+}
+
+// SetTargetBytes makes the test download exactly n bytes, however long it
+// takes, instead of running for the test duration. 0 restores timed tests.
+func (dt *DownloadTest) SetTargetBytes(n int64) {
+	dt.targetBytes = n
+}
+
+// SetDataCap stops the test once limit has been used up, e.g. shared with
+// the other phases of a run
+func (dt *DownloadTest) SetDataCap(limit *DataCap) {
+	dt.dataCap = limit
+}
+
+// SetProgress makes Measure report progress to fn every captureFreq
+func (dt *DownloadTest) SetProgress(fn func(ProgressInfo)) {
+	dt.onProgress = fn
+}
+
 // Run executes the download test
 func (dt *DownloadTest) Run(ctx context.Context, serverURL string, progress chan<- ProgressInfo) error {
-	rateCalc := NewRateCalculator()
-	rateCalc.Start()
+	_, err := dt.run(ctx, serverURL, progress)
+	return err
+}
 
-	var totalBytes int64
-	var mu sync.Mutex
-	var wg sync.WaitGroup
+func (dt *DownloadTest) run(ctx context.Context, serverURL string, progress chan<- ProgressInfo) (*phaseResult, error) {
+	stats := newURLStats()
+	m := newMeter(dt.numThreads, dt.captureFreq, dt.testDuration)
+	if dt.samples {
+		m.RecordSeries()
+	}
+	budget := phaseBudget(dt.targetBytes, dt.dataCap)
+	if dt.targetBytes > 0 {
+		m.SetTarget(dt.targetBytes)
+	}
 
-	// Try speedtest.net URLs first
 	speedtestURLs := []string{
 		fmt.Sprintf("%s/speedtest/random%dx%d.jpg", serverURL, 1000, 1000),
 		fmt.Sprintf("%s/speedtest/random%dx%d.jpg", serverURL, 500, 500),
 	}
 
-	// Try speedtest.net first, then fallbacks
-	allURLs := append(speedtestURLs, downloadTestURLs...)
-
 	// Create cancellation context with timeout
-	testCtx, cancel := context.WithTimeout(ctx, dt.testDuration)
+	testCtx, cancel := phaseContext(ctx, dt.testDuration, dt.targetBytes)
 	defer cancel()
 
-	// Run download threads
-	for i := 0; i < dt.numThreads; i++ {
-		wg.Add(1)
-		go func(threadID int) {
-			defer wg.Done()
-
-			urlIndex := 0
-			successCount := 0
-			for {
-				select {
-				case <-testCtx.Done():
-					return
-				default:
-				}
-
-				if urlIndex >= len(allURLs) {
-					urlIndex = 0 // Cycle through URLs
+	final := runPhase(ctx, m, dt.numThreads, progress, func(id int, stream *streamStats) {
+		urls := newURLSequence(speedtestURLs, dt.fallbackURLs)
+		for testCtx.Err() == nil && !budget.Spent() {
+			url := urls.URL()
+			stream.Request()
+			status, bytesRead, err := dt.fetch(testCtx, url, stream, m, budget)
+
+			// A request cut short by the end of the test is not a failure
+			if err != nil && testCtx.Err() != nil {
+				if bytesRead > 0 {
+					stats.Record(url, status, bytesRead, nil)
 				}
+				return
+			}
 
-				url := allURLs[urlIndex]
-				urlIndex++
+			stats.Record(url, status, bytesRead, err)
+			urls.Done(err == nil)
+			if err != nil {
+				sleepContext(testCtx, retryDelay)
+			}
+		}
+	})
 
-				req, err := http.NewRequestWithContext(testCtx, "GET", url, nil)
-				if err != nil {
-					continue
-				}
-				req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
+	attempts := stats.Attempts()
+	if final.BytesTotal == 0 && ctx.Err() == nil {
+		return nil, fmt.Errorf("no data downloaded from %s: %s", serverURL, attempts.Summary())
+	}
+	if final.BytesTotal < dt.targetBytes && !dt.dataCap.Reached() {
+		return nil, fmt.Errorf("downloaded only %d of %d bytes from %s: %w", final.BytesTotal, dt.targetBytes, serverURL, ctx.Err())
+	}
 
-				resp, err := dt.client.Do(req)
-				if err != nil {
-					continue
-				}
+	return &phaseResult{
+		bytes:    final.BytesTotal,
+		rate:     final.Rate,
+		protocol: final.Protocol,
+		attempts: attempts,
+		streams:  m.Streams(),
+		series:   m.Series(),
+	}, nil
+}
 
-				if resp.StatusCode == http.StatusOK {
-					bytesRead := int64(0)
-					buf := make([]byte, 32*1024)
-					for {
-						n, err := resp.Body.Read(buf)
-						if n > 0 {
-							bytesRead += int64(n)
-							mu.Lock()
-							rateCalc.SetBytes(int64(n))
-							totalBytes += int64(n)
-							mu.Unlock()
-
-							// Send progress update
-							if progress != nil {
-								select {
-								case progress <- ProgressInfo{
-									Rate:       rateCalc.Rate(),
-									BytesTotal: totalBytes,
-									Progress:   0.5,
-								}:
-								case <-testCtx.Done():
-								case <-ctx.Done():
-								}
-							}
-						}
-						if err != nil {
-							if err != io.EOF {
-							}
-							break
-						}
-					}
-					if bytesRead > 0 {
-						successCount++
-					}
-				}
-				resp.Body.Close()
-			}
-		}(i)
+// fetch downloads url into a pooled buffer, counting every chunk read on
+// stream. It stops early once budget is spent. It returns the response
+// status (0 when none arrived) and the number of bytes read.
+func (dt *DownloadTest) fetch(ctx context.Context, url string, stream *streamStats, m *meter, budget *byteBudget) (int, int64, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return 0, 0, err
 	}
 
-	wg.Wait()
+	resp, err := dt.client.Do(req)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer resp.Body.Close()
 
-	// Send final progress
-	if progress != nil {
-		close(progress)
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, 0, &statusError{url: url, code: resp.StatusCode}
 	}
+	m.SetProtocol(resp.Proto)
+
+	bufp := bufferPool.Get().(*[]byte)
+	defer bufferPool.Put(bufp)
+	buf := *bufp
+
+	bytesRead := int64(0)
+	for {
+		claim := budget.Claim(int64(len(buf)))
+		if claim == 0 {
+			// The budget is spent; the rest of the response is not needed
+			return resp.StatusCode, bytesRead, nil
+		}
 
-	return nil
+		n, err := resp.Body.Read(buf[:claim])
+		budget.Release(claim - int64(n))
+		if n > 0 {
+			bytesRead += int64(n)
+			stream.Add(int64(n))
+		}
+		if err == io.EOF {
+			return resp.StatusCode, bytesRead, nil
+		}
+		if err != nil {
+			return resp.StatusCode, bytesRead, err
+		}
+	}
 }
 
 // DownloadResult contains the final download test results
 type DownloadResult struct {
-	Bandwidth      int64         // bytes per second
-	Bytes          int64         // total bytes transferred
-	Elapsed        time.Duration // total test duration
-	URLAttempts    int           // number of URLs tried
-	FailedAttempts int           // number of failed attempts
+	Bandwidth int64                  // bytes per second
+	Bytes     int64                  // total bytes transferred
+	Elapsed   time.Duration          // total test duration
+	Protocol  string                 // negotiated HTTP protocol
+	Attempts                         // requests, status codes and errors
+	Streams   []types.StreamStats    // per-thread throughput
+	Series    []types.TransferSample // throughput over time, if enabled
 }
 
 // RunSimpleDownloadTest is a simplified download test
 func RunSimpleDownloadTest(ctx context.Context, serverURL string) (*DownloadResult, error) {
-	dt := NewDownloadTest()
-
-	result := &DownloadResult{
-		Bandwidth:      0,
-		Bytes:          0,
-		Elapsed:        0,
-		URLAttempts:    0,
-		FailedAttempts: 0,
-	}
+	return NewDownloadTest().Measure(ctx, serverURL)
+}
 
+// Measure runs the download test and collects the final result
+func (dt *DownloadTest) Measure(ctx context.Context, serverURL string) (*DownloadResult, error) {
+	progress, wait := forwardProgress(dt.onProgress)
 	start := time.Now()
+	phase, err := dt.run(ctx, serverURL, progress)
+	elapsed := time.Since(start)
+	wait()
+	if err != nil {
+		return nil, err
+	}
 
-	progress := make(chan ProgressInfo, 10)
-	errChan := make(chan error, 1)
-
-	go func() {
-		errChan <- dt.Run(ctx, serverURL, progress)
-	}()
-
-	// Wait for completion or timeout
-	select {
-	case err := <-errChan:
-		result.Elapsed = time.Since(start)
-		// Get last progress
-		for p := range progress {
-			result.Bytes = p.BytesTotal
-			result.Bandwidth = int64(p.Rate)
-		}
-		if err != nil {
-			return nil, err
-		}
-	case <-ctx.Done():
-		result.Elapsed = time.Since(start)
-		for p := range progress {
-			result.Bytes = p.BytesTotal
-			result.Bandwidth = int64(p.Rate)
-		}
+	result := &DownloadResult{
+		Bandwidth: phaseBandwidth(phase, dt.targetBytes, elapsed),
+		Bytes:     phase.bytes,
+		Elapsed:   elapsed,
+		Protocol:  phase.protocol,
+		Attempts:  phase.attempts,
+		Streams:   phase.streams,
+		Series:    phase.series,
+	}
+	if ctx.Err() != nil {
 		return result, ctx.Err()
 	}
 
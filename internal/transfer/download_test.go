@@ -4,9 +4,13 @@ import (
 	"context"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"sync"
 	"testing"
 	"time"
+
+	"github.com/user/speed-test-go/internal/network"
+	"github.com/user/speed-test-go/pkg/types"
 )
 
 func TestNewDownloadTest(t *testing.T) {
@@ -314,3 +318,308 @@ func TestDownloadTest_Timeout(t *testing.T) {
 		t.Errorf("Test took too long: %v", elapsed)
 	}
 }
+
+func TestDownloadTest_Measure_HTTP2(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(make([]byte, 1024))
+	}))
+	server.Config.Protocols = new(http.Protocols)
+	server.Config.Protocols.SetHTTP1(true)
+	server.Config.Protocols.SetUnencryptedHTTP2(true)
+	server.Start()
+	defer server.Close()
+
+	factory, err := network.NewFactory(network.Options{HTTP2: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	dt := NewDownloadTest()
+	dt.SetClient(factory.DownloadClient())
+	dt.testDuration = 200 * time.Millisecond
+
+	result, err := dt.Measure(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Protocol != "HTTP/2.0" {
+		t.Errorf("Expected HTTP/2.0 to be reported, got: %q", result.Protocol)
+	}
+}
+
+func TestDownloadTest_Measure_Fallback(t *testing.T) {
+	broken := httptest.NewServer(http.NotFoundHandler())
+	defer broken.Close()
+
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(make([]byte, 1024))
+	}))
+	defer fallback.Close()
+
+	fallbackURL := fallback.URL + "/file.bin"
+
+	// Without fallbacks nothing is downloaded from a broken server
+	dt := NewDownloadTest()
+	dt.numThreads = 1
+	dt.testDuration = 500 * time.Millisecond
+	if _, err := dt.Measure(context.Background(), broken.URL); err == nil {
+		t.Error("Expected error without fallback URLs")
+	}
+
+	dt.SetFallbackURLs([]string{fallbackURL})
+	result, err := dt.Measure(context.Background(), broken.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Bytes == 0 {
+		t.Error("Expected bytes from the fallback URL")
+	}
+
+	stats := make(map[string]types.URLStats)
+	for _, u := range result.URLs {
+		stats[u.URL] = u
+	}
+	server := stats[broken.URL+"/speedtest/random1000x1000.jpg"]
+	if server.Failures == 0 || server.Bytes != 0 {
+		t.Errorf("Expected failures recorded for the server URL, got %+v", server)
+	}
+	if got := stats[fallbackURL]; got.Successes == 0 || got.Bytes == 0 {
+		t.Errorf("Expected the fallback URL to contribute, got %+v", got)
+	}
+}
+
+func TestDownloadTest_Measure_NoData(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	dt := NewDownloadTest()
+	dt.numThreads = 2
+	dt.testDuration = 300 * time.Millisecond
+
+	_, err := dt.Measure(context.Background(), server.URL)
+	if err == nil {
+		t.Fatal("Expected error when no bytes were downloaded")
+	}
+	if !strings.Contains(err.Error(), "no data downloaded") || !strings.Contains(err.Error(), "(status: ") {
+		t.Errorf("Expected descriptive error, got: %v", err)
+	}
+}
+
+func TestDownloadTest_Measure_Attempts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Only the first of the server's URLs works
+		if strings.Contains(r.URL.Path, "500x500") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(make([]byte, 1024))
+	}))
+	defer server.Close()
+
+	dt := NewDownloadTest()
+	dt.numThreads = 1
+	dt.testDuration = 300 * time.Millisecond
+
+	result, err := dt.Measure(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.URLAttempts == 0 || result.FailedAttempts == 0 {
+		t.Fatalf("Expected attempts and failures to be counted, got %+v", result.Attempts)
+	}
+	if result.StatusCodes[200]+result.StatusCodes[404] != result.URLAttempts {
+		t.Errorf("Expected every attempt to have a status, got %v of %d", result.StatusCodes, result.URLAttempts)
+	}
+	if result.Errors[ErrorStatus] != result.FailedAttempts {
+		t.Errorf("Expected failures to be status errors, got %v", result.Errors)
+	}
+}
+
+func TestDownloadTest_Measure_Streams(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(make([]byte, 64*1024))
+	}))
+	defer server.Close()
+
+	dt := NewDownloadTest()
+	dt.numThreads = 3
+	dt.testDuration = 300 * time.Millisecond
+
+	result, err := dt.Measure(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result.Streams) != 3 {
+		t.Fatalf("Expected 3 streams, got %d", len(result.Streams))
+	}
+
+	var total int64
+	for i, st := range result.Streams {
+		if st.ID != i+1 || st.Bytes == 0 || st.Requests == 0 || st.Bandwidth <= 0 {
+			t.Errorf("Unexpected stream stats: %+v", st)
+		}
+		total += st.Bytes
+	}
+	if total < result.Bytes {
+		t.Errorf("Expected streams to account for all %d bytes, got %d", result.Bytes, total)
+	}
+}
+
+func TestDownloadTest_Measure_Samples(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(make([]byte, 64*1024))
+	}))
+	defer server.Close()
+
+	dt := NewDownloadTest()
+	dt.numThreads = 2
+	dt.testDuration = 350 * time.Millisecond
+
+	result, err := dt.Measure(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Series != nil {
+		t.Errorf("Expected no series unless enabled, got %d samples", len(result.Series))
+	}
+
+	dt.SetSamples(true)
+	result, err = dt.Measure(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Samples every captureFreq plus the final one
+	if len(result.Series) < 3 {
+		t.Fatalf("Expected samples at the capture frequency, got %d", len(result.Series))
+	}
+	for i := 1; i < len(result.Series); i++ {
+		prev, cur := result.Series[i-1], result.Series[i]
+		if cur.Elapsed < prev.Elapsed || cur.Bytes < prev.Bytes {
+			t.Errorf("Expected a growing series, got %+v after %+v", cur, prev)
+		}
+	}
+	if last := result.Series[len(result.Series)-1]; last.Bytes != result.Bytes {
+		t.Errorf("Expected the last sample to hold all %d bytes, got %d", result.Bytes, last.Bytes)
+	}
+}
+
+func TestDownloadTest_Measure_Progress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(make([]byte, 64*1024))
+	}))
+	defer server.Close()
+
+	dt := NewDownloadTest()
+	dt.numThreads = 2
+	dt.testDuration = 350 * time.Millisecond
+
+	var updates []ProgressInfo
+	dt.SetProgress(func(info ProgressInfo) {
+		updates = append(updates, info)
+	})
+
+	result, err := dt.Measure(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Measure returns only after every update has been delivered
+	if len(updates) < 2 {
+		t.Fatalf("Expected progress updates, got %d", len(updates))
+	}
+	for i := 1; i < len(updates); i++ {
+		if updates[i].Progress < updates[i-1].Progress || updates[i].ETA > updates[i-1].ETA {
+			t.Errorf("Expected progress to advance, got %+v after %+v", updates[i], updates[i-1])
+		}
+	}
+	last := updates[len(updates)-1]
+	if last.Progress != 1 || last.ETA != 0 || last.BytesTotal != result.Bytes {
+		t.Errorf("Expected a complete final update with all %d bytes, got %+v", result.Bytes, last)
+	}
+}
+
+func TestDownloadTest_Measure_TargetBytes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(make([]byte, 64*1024))
+	}))
+	defer server.Close()
+
+	const target = 1_000_003
+	dt := NewDownloadTest()
+	dt.numThreads = 3
+	// The duration does not limit a fixed-size test
+	dt.testDuration = time.Millisecond
+	dt.SetTargetBytes(target)
+
+	var last ProgressInfo
+	dt.SetProgress(func(info ProgressInfo) { last = info })
+
+	result, err := dt.Measure(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Bytes != target {
+		t.Errorf("Expected exactly %d bytes, got %d", target, result.Bytes)
+	}
+	if want := int64(float64(target) / result.Elapsed.Seconds()); result.Bandwidth != want {
+		t.Errorf("Expected the average bandwidth %d, got %d", want, result.Bandwidth)
+	}
+	var streamed int64
+	for _, st := range result.Streams {
+		streamed += st.Bytes
+	}
+	if streamed != target {
+		t.Errorf("Expected streams to add up to %d bytes, got %d", target, streamed)
+	}
+	if last.Progress != 1 || last.BytesTotal != target {
+		t.Errorf("Expected a complete final update, got %+v", last)
+	}
+}
+
+func TestDownloadTest_Measure_TargetBytesIncomplete(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(make([]byte, 1024))
+		time.Sleep(50 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	dt := NewDownloadTest()
+	dt.SetTargetBytes(1 << 30)
+	_, err := dt.Measure(ctx, server.URL)
+	if err == nil {
+		t.Fatal("Expected error when the budget could not be transferred in time")
+	}
+	if !strings.Contains(err.Error(), "of 1073741824 bytes") {
+		t.Errorf("Expected the shortfall in the error, got: %v", err)
+	}
+}
+
+func TestDownloadTest_Measure_DataCap(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(make([]byte, 64*1024))
+	}))
+	defer server.Close()
+
+	limit := NewDataCap(300_000)
+	dt := NewDownloadTest()
+	dt.testDuration = 10 * time.Second
+	dt.SetDataCap(limit)
+
+	start := time.Now()
+	result, err := dt.Measure(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Bytes != 300_000 || !limit.Reached() {
+		t.Errorf("Expected the cap to stop the download at 300000 bytes, got %d", result.Bytes)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the cap to end the test early, took %v", elapsed)
+	}
+}
@@ -0,0 +1,202 @@
+package transfer
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/user/speed-test-go/pkg/types"
+)
+
+// bufferSize is the size of the buffers transfers read into
+const bufferSize = 32 * 1024
+
+// bufferPool shares read buffers between requests and workers
+var bufferPool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, bufferSize)
+		return &buf
+	},
+}
+
+// meter accounts the bytes of a transfer phase. Workers only add to their own
+// stream's atomic counter; a single sampler reads the counters every
+// captureFreq to update the rate and report progress, so the data path never
+// takes a lock or blocks on a channel.
+type meter struct {
+	streams  []*streamStats
+	freq     time.Duration
+	duration time.Duration // expected length of the phase
+	target   int64         // bytes to transfer in fixed-size mode, 0 when timed
+	rateCalc *RateCalculator
+	protocol atomic.Pointer[string]
+	series   *transferSeries // nil unless recording
+}
+
+func newMeter(numStreams int, freq, duration time.Duration) *meter {
+	m := &meter{
+		streams:  make([]*streamStats, numStreams),
+		freq:     freq,
+		duration: duration,
+		rateCalc: NewRateCalculator(),
+	}
+	for i := range m.streams {
+		m.streams[i] = newStreamStats(i + 1)
+	}
+	m.rateCalc.Start()
+	return m
+}
+
+// RecordSeries makes every sample also append to a throughput time series
+func (m *meter) RecordSeries() {
+	m.series = newTransferSeries(m.rateCalc.startTime)
+}
+
+// SetTarget makes progress count towards transferring target bytes instead
+// of the phase duration
+func (m *meter) SetTarget(target int64) {
+	m.target = target
+}
+
+// Series returns the recorded time series, nil unless recording
+func (m *meter) Series() []types.TransferSample {
+	if m.series == nil {
+		return nil
+	}
+	return m.series.samples
+}
+
+// Stream returns the counters of worker i
+func (m *meter) Stream(i int) *streamStats {
+	return m.streams[i]
+}
+
+// SetProtocol records the protocol of a response
+func (m *meter) SetProtocol(protocol string) {
+	if current := m.protocol.Load(); current == nil || *current != protocol {
+		m.protocol.Store(&protocol)
+	}
+}
+
+// Protocol returns the protocol of the latest response
+func (m *meter) Protocol() string {
+	if p := m.protocol.Load(); p != nil {
+		return *p
+	}
+	return ""
+}
+
+// Total returns the bytes transferred by all streams
+func (m *meter) Total() int64 {
+	var total int64
+	for _, s := range m.streams {
+		total += s.Bytes()
+	}
+	return total
+}
+
+// Run samples the counters every captureFreq until ctx is done, sending each
+// sample to progress if it is not nil
+func (m *meter) Run(ctx context.Context, progress chan<- ProgressInfo) {
+	ticker := time.NewTicker(m.freq)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		info := m.Sample()
+		if progress != nil {
+			select {
+			case progress <- info:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// Sample updates the rate from the current counters. It must not be called
+// concurrently with Run.
+func (m *meter) Sample() ProgressInfo {
+	now := time.Now()
+	total := m.Total()
+	m.rateCalc.SetBytes(total)
+	if m.series != nil {
+		m.series.Add(now, total)
+	}
+
+	rate := m.rateCalc.Rate()
+	elapsed := now.Sub(m.rateCalc.startTime)
+	progress, eta := phaseProgress(elapsed, m.duration)
+	if m.target > 0 {
+		progress, eta = budgetProgress(total, m.target, rate)
+	}
+	return ProgressInfo{
+		Rate:       rate,
+		BytesTotal: total,
+		Progress:   progress,
+		Elapsed:    elapsed,
+		ETA:        eta,
+		Protocol:   m.Protocol(),
+	}
+}
+
+// Streams returns the totals of every stream
+func (m *meter) Streams() []types.StreamStats {
+	list := make([]types.StreamStats, 0, len(m.streams))
+	for _, s := range m.streams {
+		list = append(list, s.Stats())
+	}
+	return list
+}
+
+// phaseResult is what a transfer phase measured
+type phaseResult struct {
+	bytes    int64
+	rate     float64 // bytes per second
+	protocol string
+	attempts Attempts
+	streams  []types.StreamStats
+	series   []types.TransferSample
+}
+
+// runPhase runs work on numThreads workers until they return, sampling their
+// counters with m and sending progress. progress is closed when done.
+func runPhase(ctx context.Context, m *meter, numThreads int, progress chan<- ProgressInfo, work func(id int, stream *streamStats)) ProgressInfo {
+	samplerCtx, stopSampler := context.WithCancel(ctx)
+	sampled := make(chan struct{})
+	go func() {
+		defer close(sampled)
+		m.Run(samplerCtx, progress)
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < numThreads; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			work(id, m.Stream(id))
+		}(i)
+	}
+	wg.Wait()
+
+	stopSampler()
+	<-sampled
+
+	// Send final progress; the phase is complete even if it ended early
+	final := m.Sample()
+	final.Progress, final.ETA = 1, 0
+	if progress != nil {
+		select {
+		case progress <- final:
+		case <-ctx.Done():
+		}
+		close(progress)
+	}
+	return final
+}
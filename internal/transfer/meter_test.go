@@ -0,0 +1,174 @@
+package transfer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newLoopbackServer serves size bytes per request from a shared buffer
+func newLoopbackServer(tb testing.TB, size int64) *httptest.Server {
+	tb.Helper()
+
+	chunk := make([]byte, 1024*1024)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+		for remaining := size; remaining > 0; {
+			n := min(remaining, int64(len(chunk)))
+			if _, err := w.Write(chunk[:n]); err != nil {
+				return
+			}
+			remaining -= n
+		}
+	}))
+	tb.Cleanup(server.Close)
+	return server
+}
+
+func TestMeter_Sample(t *testing.T) {
+	m := newMeter(2, time.Hour, time.Hour)
+	m.Stream(0).Add(1000)
+	m.Stream(1).Add(500)
+	m.SetProtocol("HTTP/1.1")
+
+	time.Sleep(10 * time.Millisecond)
+	info := m.Sample()
+	if info.BytesTotal != 1500 || info.Protocol != "HTTP/1.1" {
+		t.Errorf("Unexpected sample: %+v", info)
+	}
+	if info.Rate <= 0 {
+		t.Errorf("Expected positive rate, got %f", info.Rate)
+	}
+}
+
+func TestMeter_SampleProgress(t *testing.T) {
+	m := newMeter(1, time.Hour, 200*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+
+	info := m.Sample()
+	if info.Progress <= 0 || info.Progress >= 1 {
+		t.Errorf("Expected progress between 0 and 1, got %f", info.Progress)
+	}
+	if info.ETA <= 0 || info.ETA > 150*time.Millisecond {
+		t.Errorf("Expected ETA under 150ms, got %v", info.ETA)
+	}
+	if info.Elapsed+info.ETA != 200*time.Millisecond {
+		t.Errorf("Expected elapsed plus ETA to be the duration, got %v + %v", info.Elapsed, info.ETA)
+	}
+}
+
+func TestPhaseProgress(t *testing.T) {
+	tests := []struct {
+		elapsed, duration time.Duration
+		progress          float64
+		eta               time.Duration
+	}{
+		{0, 10 * time.Second, 0, 10 * time.Second},
+		{2500 * time.Millisecond, 10 * time.Second, 0.25, 7500 * time.Millisecond},
+		{10 * time.Second, 10 * time.Second, 1, 0},
+		{12 * time.Second, 10 * time.Second, 1, 0},
+		{time.Second, 0, 1, 0},
+	}
+
+	for _, tt := range tests {
+		progress, eta := phaseProgress(tt.elapsed, tt.duration)
+		if progress != tt.progress || eta != tt.eta {
+			t.Errorf("phaseProgress(%v, %v) = %f, %v; want %f, %v", tt.elapsed, tt.duration, progress, eta, tt.progress, tt.eta)
+		}
+	}
+}
+
+func TestRunPhase_Progress(t *testing.T) {
+	m := newMeter(4, 10*time.Millisecond, time.Hour)
+	progress := make(chan ProgressInfo, 100)
+
+	var samples atomic.Int32
+	received := make(chan struct{})
+	go func() {
+		defer close(received)
+		for range progress {
+			samples.Add(1)
+		}
+	}()
+
+	final := runPhase(context.Background(), m, 4, progress, func(id int, stream *streamStats) {
+		for i := 0; i < 10; i++ {
+			stream.Add(1024)
+			time.Sleep(5 * time.Millisecond)
+		}
+	})
+	<-received
+
+	if final.BytesTotal != 4*10*1024 {
+		t.Errorf("Expected %d bytes, got %d", 4*10*1024, final.BytesTotal)
+	}
+	// The phase ended before its duration but is complete
+	if final.Progress != 1 || final.ETA != 0 {
+		t.Errorf("Expected a complete final sample, got progress %f, ETA %v", final.Progress, final.ETA)
+	}
+	// Periodic samples plus the final one
+	if samples.Load() < 2 {
+		t.Errorf("Expected several progress samples, got %d", samples.Load())
+	}
+}
+
+func BenchmarkStreamStats_Add(b *testing.B) {
+	m := newMeter(1, time.Hour, time.Hour)
+	stream := m.Stream(0)
+
+	b.SetBytes(bufferSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		stream.Add(bufferSize)
+	}
+}
+
+// BenchmarkStreamStats_AddParallel adds from many goroutines, each to its
+// own stream as the workers do
+func BenchmarkStreamStats_AddParallel(b *testing.B) {
+	// RunParallel starts GOMAXPROCS goroutines
+	m := newMeter(runtime.GOMAXPROCS(0), time.Hour, time.Hour)
+	var next atomic.Int32
+
+	b.SetBytes(bufferSize)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		stream := m.Stream(int(next.Add(1) - 1))
+		for pb.Next() {
+			stream.Add(bufferSize)
+		}
+	})
+}
+
+// BenchmarkDownloadTest_Loopback downloads 64 MiB per operation from a local
+// server over four parallel streams; the reported MB/s is the throughput the
+// transfer engine sustains
+func BenchmarkDownloadTest_Loopback(b *testing.B) {
+	const size = 16 * 1024 * 1024
+	const threads = 4
+	server := newLoopbackServer(b, size)
+
+	dt := NewDownloadTest()
+	m := newMeter(threads, dt.captureFreq, dt.testDuration)
+	ctx := context.Background()
+	url := server.URL + "/speedtest/random1000x1000.jpg"
+
+	b.SetBytes(size * threads)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		runPhase(ctx, m, threads, nil, func(id int, stream *streamStats) {
+			if _, _, err := dt.fetch(ctx, url, stream, m, nil); err != nil {
+				b.Error(err)
+			}
+		})
+	}
+	b.StopTimer()
+
+	elapsed := b.Elapsed().Seconds()
+	b.ReportMetric(float64(m.Total())*8/elapsed/1e9, "Gbps")
+}
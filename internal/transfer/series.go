@@ -0,0 +1,81 @@
+package transfer
+
+import (
+	"sync"
+	"time"
+
+	"github.com/user/speed-test-go/pkg/types"
+)
+
+// transferSeries records the throughput of a phase each time its meter samples
+type transferSeries struct {
+	start     time.Time
+	lastTime  time.Time
+	lastBytes int64
+	samples   []types.TransferSample
+}
+
+func newTransferSeries(start time.Time) *transferSeries {
+	return &transferSeries{start: start, lastTime: start}
+}
+
+// Add records the total bytes transferred at now
+func (s *transferSeries) Add(now time.Time, total int64) {
+	var rate float64
+	if elapsed := now.Sub(s.lastTime); elapsed > 0 {
+		rate = float64(total-s.lastBytes) / elapsed.Seconds()
+	}
+	s.samples = append(s.samples, types.TransferSample{
+		Elapsed: now.Sub(s.start).Milliseconds(),
+		Bytes:   total,
+		Rate:    rate,
+	})
+	s.lastTime, s.lastBytes = now, total
+}
+
+// LatencySeries records latency probes with the time they completed. Probes
+// may record concurrently; all methods do nothing on a nil series, so probes
+// can record unconditionally.
+type LatencySeries struct {
+	mu      sync.Mutex
+	start   time.Time
+	samples []types.LatencySample
+}
+
+// NewLatencySeries creates a series starting now
+func NewLatencySeries() *LatencySeries {
+	return &LatencySeries{start: time.Now()}
+}
+
+// Add records a probe that completed now
+func (s *LatencySeries) Add(latency time.Duration) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.samples = append(s.samples, types.LatencySample{
+		Elapsed: time.Since(s.start).Milliseconds(),
+		Latency: float64(latency.Microseconds()) / 1000,
+	})
+}
+
+// Reset discards the recorded probes, e.g. before falling back to another method
+func (s *LatencySeries) Reset() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.samples = nil
+}
+
+// Samples returns the recorded probes
+func (s *LatencySeries) Samples() []types.LatencySample {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]types.LatencySample(nil), s.samples...)
+}
@@ -0,0 +1,74 @@
+package transfer
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTransferSeries(t *testing.T) {
+	start := time.Now()
+	series := newTransferSeries(start)
+
+	series.Add(start.Add(100*time.Millisecond), 1000)
+	series.Add(start.Add(300*time.Millisecond), 5000)
+
+	if len(series.samples) != 2 {
+		t.Fatalf("Expected 2 samples, got %d", len(series.samples))
+	}
+
+	tests := []struct {
+		elapsed int64
+		bytes   int64
+		rate    float64
+	}{
+		{100, 1000, 10000},
+		{300, 5000, 20000},
+	}
+	for i, tt := range tests {
+		got := series.samples[i]
+		if got.Elapsed != tt.elapsed || got.Bytes != tt.bytes || got.Rate != tt.rate {
+			t.Errorf("Sample %d: expected %+v, got %+v", i, tt, got)
+		}
+	}
+}
+
+func TestLatencySeries(t *testing.T) {
+	series := NewLatencySeries()
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 5; i++ {
+		wg.Add(1)
+		go func(ms int) {
+			defer wg.Done()
+			series.Add(time.Duration(ms) * time.Millisecond)
+		}(i)
+	}
+	wg.Wait()
+
+	samples := series.Samples()
+	if len(samples) != 5 {
+		t.Fatalf("Expected 5 samples, got %d", len(samples))
+	}
+	for _, s := range samples {
+		if s.Latency < 1 || s.Latency > 5 || s.Elapsed < 0 {
+			t.Errorf("Unexpected sample: %+v", s)
+		}
+	}
+
+	series.Reset()
+	if len(series.Samples()) != 0 {
+		t.Error("Expected no samples after Reset")
+	}
+}
+
+func TestLatencySeries_Nil(t *testing.T) {
+	var series *LatencySeries
+
+	// A nil series records nothing and must not panic
+	series.Add(time.Millisecond)
+	series.Reset()
+	if series.Samples() != nil {
+		t.Error("Expected nil samples from a nil series")
+	}
+}
@@ -0,0 +1,56 @@
+package transfer
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// byteUnits are the size suffixes accepted by ParseBytes. KB, MB and GB are
+// decimal like the sizes in the output; KiB, MiB and GiB are binary.
+var byteUnits = []struct {
+	suffix string
+	size   float64
+}{
+	{"KIB", 1 << 10},
+	{"MIB", 1 << 20},
+	{"GIB", 1 << 30},
+	{"KB", 1e3},
+	{"MB", 1e6},
+	{"GB", 1e9},
+	{"K", 1e3},
+	{"M", 1e6},
+	{"G", 1e9},
+	{"B", 1},
+}
+
+// ParseBytes parses a size such as "500MB", "1.5GB", "64MiB" or "1048576"
+func ParseBytes(s string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(s))
+	multiplier := 1.0
+	for _, unit := range byteUnits {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			multiplier = unit.size
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: expected a number with an optional unit, e.g. 500MB", s)
+	}
+	if math.IsNaN(n) || math.IsInf(n, 0) {
+		return 0, fmt.Errorf("invalid size %q: expected a number with an optional unit, e.g. 500MB", s)
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("invalid size %q: must not be negative", s)
+	}
+	// float64(math.MaxInt64) rounds up to 2^63, which no int64 can hold
+	bytes := n * multiplier
+	if bytes >= math.MaxInt64 {
+		return 0, fmt.Errorf("invalid size %q: too large", s)
+	}
+	return int64(bytes), nil
+}
@@ -0,0 +1,45 @@
+package transfer
+
+import "testing"
+
+func TestParseBytes(t *testing.T) {
+	tests := []struct {
+		input string
+		want  int64
+	}{
+		{"1048576", 1048576},
+		{"500B", 500},
+		{"500MB", 500_000_000},
+		{"500mb", 500_000_000},
+		{"1.5GB", 1_500_000_000},
+		{"64 KB", 64_000},
+		{"64MiB", 64 << 20},
+		{"2GiB", 2 << 30},
+		{"10M", 10_000_000},
+		{"0", 0},
+		{"9e18B", 9_000_000_000_000_000_000},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseBytes(tt.input)
+		if err != nil {
+			t.Errorf("ParseBytes(%q) failed: %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseBytes(%q) = %d, want %d", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseBytes_Invalid(t *testing.T) {
+	for _, input := range []string{
+		"", "MB", "ten MB", "5TB", "-1MB",
+		"inf", "+InfGB", "NaN", "nanMB",
+		"1e30GB", "9223372036854775808", "9.3e18B", "10GGB",
+	} {
+		if _, err := ParseBytes(input); err == nil {
+			t.Errorf("ParseBytes(%q): expected error", input)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/user/speed-test-go/pkg/types"
+)
+
+// retryDelay is how long a worker waits after a failed request before the
+// next one, so unreachable URLs don't turn into a busy loop
+const retryDelay = 100 * time.Millisecond
+
+// urlSequence hands a worker the server's URLs in turn. With fallback URLs
+// configured it switches to them for good once every server URL has failed
+// in a row, so fallbacks are never mixed into a working server's results.
+type urlSequence struct {
+	urls      []string
+	fallbacks []string
+	next      int
+	failures  int // consecutive failures
+}
+
+func newURLSequence(urls, fallbacks []string) *urlSequence {
+	return &urlSequence{urls: urls, fallbacks: fallbacks}
+}
+
+// URL returns the URL to request next
+func (s *urlSequence) URL() string {
+	url := s.urls[s.next%len(s.urls)]
+	s.next++
+	return url
+}
+
+// Done records the outcome of the last request
+func (s *urlSequence) Done(ok bool) {
+	if ok {
+		s.failures = 0
+		return
+	}
+
+	s.failures++
+	if s.failures >= len(s.urls) && len(s.fallbacks) > 0 {
+		s.urls, s.fallbacks = s.fallbacks, nil
+		s.next, s.failures = 0, 0
+	}
+}
+
+// Categories of failed requests
+const (
+	ErrorDNS     = "dns"
+	ErrorConnect = "connect"
+	ErrorTimeout = "timeout"
+	ErrorReset   = "reset"
+	ErrorStatus  = "status" // the server answered with a status other than 200
+	ErrorOther   = "other"
+)
+
+// statusError is returned for responses other than 200 OK
+type statusError struct {
+	url  string
+	code int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%s returned status %d", e.url, e.code)
+}
+
+// classifyError returns the category of a failed request
+func classifyError(err error) string {
+	var statusErr *statusError
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	var opErr *net.OpError
+
+	switch {
+	case errors.As(err, &statusErr):
+		return ErrorStatus
+	case errors.As(err, &dnsErr):
+		return ErrorDNS
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorTimeout
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE), errors.Is(err, io.ErrUnexpectedEOF):
+		return ErrorReset
+	case errors.As(err, &opErr) && opErr.Op == "dial":
+		return ErrorConnect
+	default:
+		return ErrorOther
+	}
+}
+
+// Attempts summarizes the requests made during a transfer phase
+type Attempts struct {
+	URLAttempts    int              // number of requests made
+	FailedAttempts int              // number of failed requests
+	StatusCodes    map[int]int      // responses by HTTP status
+	Errors         map[string]int   // failed requests by category
+	URLs           []types.URLStats // per-URL bytes, successes and failures
+}
+
+// Summary describes the attempts for error messages, e.g.
+// "12 of 12 requests failed (connect: 12)"
+func (a Attempts) Summary() string {
+	if a.URLAttempts == 0 {
+		return "no requests completed"
+	}
+
+	categories := make([]string, 0, len(a.Errors))
+	for category := range a.Errors {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	parts := make([]string, 0, len(categories))
+	for _, category := range categories {
+		parts = append(parts, fmt.Sprintf("%s: %d", category, a.Errors[category]))
+	}
+
+	summary := fmt.Sprintf("%d of %d requests failed", a.FailedAttempts, a.URLAttempts)
+	if len(parts) > 0 {
+		summary += " (" + strings.Join(parts, ", ") + ")"
+	}
+	return summary
+}
+
+// urlStats collects per-URL transfer statistics from concurrent workers
+type urlStats struct {
+	mu       sync.Mutex
+	order    []string
+	stats    map[string]*types.URLStats
+	attempts Attempts
+}
+
+func newURLStats() *urlStats {
+	return &urlStats{
+		stats: make(map[string]*types.URLStats),
+		attempts: Attempts{
+			StatusCodes: make(map[int]int),
+			Errors:      make(map[string]int),
+		},
+	}
+}
+
+func (s *urlStats) get(url string) *types.URLStats {
+	st, ok := s.stats[url]
+	if !ok {
+		st = &types.URLStats{URL: url}
+		s.stats[url] = st
+		s.order = append(s.order, url)
+	}
+	return st
+}
+
+// Record counts a request to url that got a response with status (0 when
+// none arrived), transferred bytes and failed with err, if not nil
+func (s *urlStats) Record(url string, status int, bytes int64, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := s.get(url)
+	st.Bytes += bytes
+	s.attempts.URLAttempts++
+	if status != 0 {
+		s.attempts.StatusCodes[status]++
+	}
+
+	if err != nil {
+		st.Failures++
+		s.attempts.FailedAttempts++
+		s.attempts.Errors[classifyError(err)]++
+		return
+	}
+	st.Successes++
+}
+
+// Attempts returns the summary with URLs in the order they were first requested
+func (s *urlStats) Attempts() Attempts {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempts := s.attempts
+	attempts.StatusCodes = maps.Clone(s.attempts.StatusCodes)
+	attempts.Errors = maps.Clone(s.attempts.Errors)
+	attempts.URLs = make([]types.URLStats, 0, len(s.order))
+	for _, url := range s.order {
+		attempts.URLs = append(attempts.URLs, *s.stats[url])
+	}
+	return attempts
+}
+
+// peakWindow is the interval over which a stream's peak rate is measured
+const peakWindow = 250 * time.Millisecond
+
+// streamStats accounts the transfer of a single worker. Only the byte count
+// is read by other goroutines while the worker runs; the rest belongs to the
+// worker until it has finished.
+type streamStats struct {
+	id          int
+	bytes       atomic.Int64
+	start       time.Time
+	last        time.Time // time of the last byte
+	requests    int
+	peak        float64
+	windowStart time.Time
+	windowBytes int64
+}
+
+func newStreamStats(id int) *streamStats {
+	now := time.Now()
+	return &streamStats{id: id, start: now, last: now, windowStart: now}
+}
+
+// Add counts n bytes transferred now
+func (s *streamStats) Add(n int64) {
+	now := time.Now()
+	s.bytes.Add(n)
+	s.last = now
+
+	s.windowBytes += n
+	if elapsed := now.Sub(s.windowStart); elapsed >= peakWindow {
+		s.peak = max(s.peak, float64(s.windowBytes)/elapsed.Seconds())
+		s.windowStart, s.windowBytes = now, 0
+	}
+}
+
+// Bytes returns the bytes transferred so far; safe to call from any goroutine
+func (s *streamStats) Bytes() int64 {
+	return s.bytes.Load()
+}
+
+// Request counts a request made on the stream
+func (s *streamStats) Request() {
+	s.requests++
+}
+
+// Stats returns the stream's totals
+func (s *streamStats) Stats() types.StreamStats {
+	bytes := s.Bytes()
+	stats := types.StreamStats{
+		ID:       s.id,
+		Bytes:    bytes,
+		Requests: s.requests,
+	}
+	if bytes == 0 {
+		return stats
+	}
+
+	duration := s.last.Sub(s.start)
+	stats.Duration = duration.Milliseconds()
+	if duration > 0 {
+		stats.Bandwidth = int64(float64(bytes) / duration.Seconds())
+	}
+	// Streams shorter than a window only have their average
+	stats.PeakBandwidth = max(int64(s.peak), stats.Bandwidth)
+	return stats
+}
+
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
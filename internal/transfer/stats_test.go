@@ -0,0 +1,166 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/user/speed-test-go/pkg/types"
+)
+
+func TestURLSequence(t *testing.T) {
+	seq := newURLSequence([]string{"a", "b"}, []string{"x"})
+
+	var got []string
+	for _, ok := range []bool{true, false, true, false, false, false, true} {
+		got = append(got, seq.URL())
+		seq.Done(ok)
+	}
+
+	// Switches to the fallback only after both server URLs failed in a row
+	want := []string{"a", "b", "a", "b", "a", "x", "x"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestURLSequence_NoFallback(t *testing.T) {
+	seq := newURLSequence([]string{"a", "b"}, nil)
+
+	for i := 0; i < 5; i++ {
+		seq.Done(false)
+	}
+	if url := seq.URL(); url != "a" {
+		t.Errorf("Expected to stay on the server URLs, got %q", url)
+	}
+}
+
+func TestURLStats(t *testing.T) {
+	stats := newURLStats()
+	stats.Record("b", 200, 100, nil)
+	stats.Record("a", 200, 10, io.ErrUnexpectedEOF)
+	stats.Record("b", 200, 50, nil)
+	stats.Record("a", 404, 0, &statusError{url: "a", code: 404})
+	stats.Record("a", 0, 0, &net.DNSError{Err: "no such host", Name: "a"})
+
+	got := stats.Attempts()
+	want := Attempts{
+		URLAttempts:    5,
+		FailedAttempts: 3,
+		StatusCodes:    map[int]int{200: 3, 404: 1},
+		Errors:         map[string]int{ErrorReset: 1, ErrorStatus: 1, ErrorDNS: 1},
+		URLs: []types.URLStats{
+			{URL: "b", Bytes: 150, Successes: 2},
+			{URL: "a", Bytes: 10, Failures: 3},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"status", &statusError{url: "u", code: 500}, ErrorStatus},
+		{"dns", &url.Error{Op: "Get", URL: "u", Err: &net.DNSError{Err: "no such host"}}, ErrorDNS},
+		{"deadline", context.DeadlineExceeded, ErrorTimeout},
+		{"reset", &net.OpError{Op: "read", Err: syscall.ECONNRESET}, ErrorReset},
+		{"unexpected eof", io.ErrUnexpectedEOF, ErrorReset},
+		{"refused", &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, ErrorConnect},
+		{"other", errors.New("boom"), ErrorOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyError(tt.err); got != tt.want {
+				t.Errorf("classifyError(%v) = %q, want %q", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassifyError_Refused(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	_, err = http.Get("http://" + addr)
+	if err == nil {
+		t.Fatal("Expected connection to a closed port to fail")
+	}
+	if got := classifyError(err); got != ErrorConnect {
+		t.Errorf("Expected %q, got %q for %v", ErrorConnect, got, err)
+	}
+}
+
+func TestAttempts_Summary(t *testing.T) {
+	tests := []struct {
+		attempts Attempts
+		want     string
+	}{
+		{Attempts{}, "no requests completed"},
+		{Attempts{URLAttempts: 4}, "0 of 4 requests failed"},
+		{
+			Attempts{URLAttempts: 12, FailedAttempts: 12, Errors: map[string]int{ErrorTimeout: 2, ErrorConnect: 10}},
+			"12 of 12 requests failed (connect: 10, timeout: 2)",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := tt.attempts.Summary(); got != tt.want {
+			t.Errorf("Summary() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestStreamStats(t *testing.T) {
+	stream := newStreamStats(3)
+	stream.Request()
+	stream.Request()
+
+	start := time.Now().Add(-time.Second)
+	stream.start, stream.last, stream.windowStart = start, start, start
+
+	// A burst over one window followed by a slower period
+	stream.Add(500_000)
+	stream.windowStart = time.Now()
+	stream.Add(100)
+
+	stats := stream.Stats()
+	if stats.ID != 3 || stats.Bytes != 500_100 || stats.Requests != 2 {
+		t.Errorf("Unexpected totals: %+v", stats)
+	}
+	if stats.Duration < 1000 {
+		t.Errorf("Expected duration of at least 1s, got %d ms", stats.Duration)
+	}
+	if stats.Bandwidth <= 0 || stats.Bandwidth > 500_100 {
+		t.Errorf("Expected average below 500 kB/s, got %d", stats.Bandwidth)
+	}
+	if stats.PeakBandwidth < stats.Bandwidth {
+		t.Errorf("Expected peak %d to be at least the average %d", stats.PeakBandwidth, stats.Bandwidth)
+	}
+}
+
+func TestStreamStats_Empty(t *testing.T) {
+	stream := newStreamStats(1)
+	stream.Request()
+
+	want := types.StreamStats{ID: 1, Requests: 1}
+	if got := stream.Stats(); got != want {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+}
@@ -0,0 +1,368 @@
+package transfer
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Smallest transfers the protocol can carry: a download reply echoes the
+// command and ends with a newline, and an upload needs its command line, e.g.
+// "UPLOAD 13 0\n", and a one-byte body
+const (
+	minTCPDownload = int64(len("DOWNLOAD \n"))
+	minTCPUpload   = int64(len("UPLOAD 13 0\n")) + 1
+)
+
+// DialFunc connects to an address, normally network.Factory.DialContext
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// TCPConn is a connection speaking the line-based speedtest.net TCP protocol
+// (HI, PING, DOWNLOAD, UPLOAD and QUIT commands)
+type TCPConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	stop   func() bool
+
+	// Greeting is the server's HELLO line, e.g. "HELLO 2.9 (2.9.3) 2020-03-13.0025.4b9e9ad"
+	Greeting string
+}
+
+// DialTCP connects to a speedtest server and performs the HI/HELLO handshake.
+// The connection is closed when ctx ends.
+func DialTCP(ctx context.Context, dial DialFunc, addr string) (*TCPConn, error) {
+	if dial == nil {
+		var d net.Dialer
+		dial = d.DialContext
+	}
+
+	conn, err := dial(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+
+	c := &TCPConn{
+		conn:   conn,
+		reader: bufio.NewReader(conn),
+		stop:   context.AfterFunc(ctx, func() { conn.Close() }),
+	}
+
+	greeting, err := c.command("HI")
+	if err != nil {
+		c.conn.Close()
+		return nil, err
+	}
+	if !strings.HasPrefix(greeting, "HELLO") {
+		c.conn.Close()
+		return nil, fmt.Errorf("unexpected greeting from %s: %q", addr, greeting)
+	}
+	c.Greeting = greeting
+
+	return c, nil
+}
+
+// Ping measures one PING/PONG round trip
+func (c *TCPConn) Ping() (time.Duration, error) {
+	start := time.Now()
+	reply, err := c.command("PING " + strconv.FormatInt(start.UnixMilli(), 10))
+	if err != nil {
+		return 0, err
+	}
+	if !strings.HasPrefix(reply, "PONG") {
+		return 0, fmt.Errorf("unexpected ping reply: %q", reply)
+	}
+	return time.Since(start), nil
+}
+
+// Download requests size bytes and reads them, reporting each chunk to onBytes
+func (c *TCPConn) Download(size int64, onBytes func(int64)) (int64, error) {
+	if _, err := fmt.Fprintf(c.conn, "DOWNLOAD %d\n", size); err != nil {
+		return 0, fmt.Errorf("failed to send download command: %w", err)
+	}
+
+	n, err := io.CopyN(&countingWriter{onBytes: onBytes}, c.reader, size)
+	if err != nil {
+		return n, fmt.Errorf("download interrupted after %d bytes: %w", n, err)
+	}
+	return n, nil
+}
+
+// Upload sends size bytes (including the command line) taken from payload and
+// waits for the server's OK, reporting each chunk to onBytes
+func (c *TCPConn) Upload(size int64, payload []byte, onBytes func(int64)) (int64, error) {
+	header := fmt.Sprintf("UPLOAD %d 0\n", size)
+	if size <= int64(len(header)) || len(payload) == 0 {
+		return 0, fmt.Errorf("upload size %d is too small", size)
+	}
+
+	if _, err := io.WriteString(c.conn, header); err != nil {
+		return 0, fmt.Errorf("failed to send upload command: %w", err)
+	}
+	sent := int64(len(header))
+	onBytes(sent)
+
+	// The body must end with a newline for the server to complete the upload
+	for sent < size {
+		chunk := payload
+		if remaining := size - sent; remaining <= int64(len(chunk)) {
+			chunk = append(payload[:remaining-1:remaining-1], '\n')
+		}
+		n, err := c.conn.Write(chunk)
+		sent += int64(n)
+		onBytes(int64(n))
+		if err != nil {
+			return sent, fmt.Errorf("upload interrupted after %d bytes: %w", sent, err)
+		}
+	}
+
+	reply, err := c.readLine()
+	if err != nil {
+		return sent, err
+	}
+	if !strings.HasPrefix(reply, "OK") {
+		return sent, fmt.Errorf("unexpected upload reply: %q", reply)
+	}
+	return sent, nil
+}
+
+// Close sends QUIT and closes the connection
+func (c *TCPConn) Close() error {
+	c.stop()
+	io.WriteString(c.conn, "QUIT\n")
+	return c.conn.Close()
+}
+
+// command sends a single command line and returns the reply line
+func (c *TCPConn) command(cmd string) (string, error) {
+	if _, err := io.WriteString(c.conn, cmd+"\n"); err != nil {
+		return "", fmt.Errorf("failed to send %s: %w", strings.Fields(cmd)[0], err)
+	}
+	return c.readLine()
+}
+
+func (c *TCPConn) readLine() (string, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("failed to read reply: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// countingWriter discards data while reporting how much was written
+type countingWriter struct {
+	onBytes func(int64)
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.onBytes(int64(len(p)))
+	return len(p), nil
+}
+
+// TCPTest measures latency and throughput with the speedtest.net TCP protocol
+type TCPTest struct {
+	dial         DialFunc
+	numThreads   int
+	numPings     int
+	testDuration time.Duration
+	chunkSize    int64
+	samples      bool
+	latency      *LatencySeries
+	targetBytes  int64
+	dataCap      *DataCap
+	onProgress   func(ProgressInfo)
+}
+
+// NewTCPTest creates a TCP protocol test connecting with dial (nil for a plain dialer)
+func NewTCPTest(dial DialFunc) *TCPTest {
+	return &TCPTest{
+		dial:         dial,
+		numThreads:   4,
+		numPings:     10,
+		testDuration: 15 * time.Second,
+		chunkSize:    1 * 1024 * 1024, // 1MB per command
+	}
+}
+
+// SetSamples enables recording a throughput time series for transfers
+func (tt *TCPTest) SetSamples(enabled bool) {
+	tt.samples = enabled
+}
+
+// SetLatencySeries records every ping in series
+func (tt *TCPTest) SetLatencySeries(series *LatencySeries) {
+	tt.latency = series
+}
+
+// SetTargetBytes makes transfers move exactly n bytes, however long they
+// take, instead of running for the test duration. 0 restores timed tests.
+func (tt *TCPTest) SetTargetBytes(n int64) {
+	tt.targetBytes = n
+}
+
+// SetDataCap stops transfers once limit has been used up
+func (tt *TCPTest) SetDataCap(limit *DataCap) {
+	tt.dataCap = limit
+}
+
+// SetProgress reports the progress of transfers to fn
+func (tt *TCPTest) SetProgress(fn func(ProgressInfo)) {
+	tt.onProgress = fn
+}
+
+// Ping measures PING/PONG round trips over a single connection
+func (tt *TCPTest) Ping(ctx context.Context, addr string) ([]time.Duration, error) {
+	conn, err := DialTCP(ctx, tt.dial, addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	latencies := make([]time.Duration, 0, tt.numPings)
+	for i := 0; i < tt.numPings; i++ {
+		latency, err := conn.Ping()
+		if err != nil {
+			return latencies, err
+		}
+		latencies = append(latencies, latency)
+		tt.latency.Add(latency)
+	}
+	return latencies, nil
+}
+
+// Download repeatedly downloads chunks over parallel connections for the test duration
+func (tt *TCPTest) Download(ctx context.Context, addr string) (*DownloadResult, error) {
+	phase, elapsed, err := tt.run(ctx, addr, minTCPDownload, func(conn *TCPConn, size int64, onBytes func(int64)) (int64, error) {
+		return conn.Download(size, onBytes)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("tcp download failed: %w", err)
+	}
+
+	return &DownloadResult{
+		Bandwidth: phaseBandwidth(phase, tt.targetBytes, elapsed),
+		Bytes:     phase.bytes,
+		Elapsed:   elapsed,
+		Protocol:  "tcp",
+		Streams:   phase.streams,
+		Series:    phase.series,
+	}, nil
+}
+
+// Upload repeatedly uploads chunks over parallel connections for the test duration
+func (tt *TCPTest) Upload(ctx context.Context, addr string) (*UploadResult, error) {
+	payload := make([]byte, 32*1024)
+	rand.Read(payload)
+
+	phase, elapsed, err := tt.run(ctx, addr, minTCPUpload, func(conn *TCPConn, size int64, onBytes func(int64)) (int64, error) {
+		return conn.Upload(size, payload, onBytes)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("tcp upload failed: %w", err)
+	}
+
+	return &UploadResult{
+		Bandwidth: phaseBandwidth(phase, tt.targetBytes, elapsed),
+		Bytes:     phase.bytes,
+		Elapsed:   elapsed,
+		Protocol:  "tcp",
+		Streams:   phase.streams,
+		Series:    phase.series,
+	}, nil
+}
+
+// run executes transfers of up to chunkSize bytes on numThreads connections
+// until the test duration ends or the target bytes have been transferred, and
+// returns what was measured and the elapsed time. Transfers are at least
+// minSize bytes, so a smaller remainder of the budget is added to the transfer
+// before it. An error is returned only if no data was transferred at all, or
+// less than the target.
+func (tt *TCPTest) run(ctx context.Context, addr string, minSize int64, transfer func(conn *TCPConn, size int64, onBytes func(int64)) (int64, error)) (*phaseResult, time.Duration, error) {
+	budget := phaseBudget(tt.targetBytes, tt.dataCap)
+	testCtx, cancel := phaseContext(ctx, tt.testDuration, tt.targetBytes)
+	defer cancel()
+
+	m := newMeter(tt.numThreads, 100*time.Millisecond, tt.testDuration)
+	if tt.samples {
+		m.RecordSeries()
+	}
+	if tt.targetBytes > 0 {
+		m.SetTarget(tt.targetBytes)
+	}
+	progress, wait := forwardProgress(tt.onProgress)
+	defer wait()
+	start := time.Now()
+
+	var firstErr error
+	var mu, claimMu sync.Mutex
+	recordErr := func(err error) {
+		mu.Lock()
+		if firstErr == nil {
+			firstErr = err
+		}
+		mu.Unlock()
+	}
+
+	final := runPhase(ctx, m, tt.numThreads, progress, func(id int, stream *streamStats) {
+		conn, err := DialTCP(testCtx, tt.dial, addr)
+		if err != nil {
+			recordErr(err)
+			return
+		}
+		defer conn.Close()
+
+		for testCtx.Err() == nil {
+			// Claims are serialized so no other stream can take a small
+			// remainder between the two
+			claimMu.Lock()
+			size := budget.Claim(tt.chunkSize)
+			if rest := budget.Available(); rest > 0 && rest < minSize {
+				size += budget.Claim(rest)
+			}
+			claimMu.Unlock()
+			if size < minSize {
+				budget.Release(size)
+				return
+			}
+
+			stream.Request()
+			n, err := transfer(conn, size, stream.Add)
+			budget.Release(size - n)
+			if err != nil {
+				// Errors caused by the end of the test are expected
+				if testCtx.Err() == nil {
+					recordErr(err)
+				}
+				return
+			}
+		}
+	})
+	elapsed := time.Since(start)
+
+	if final.BytesTotal == 0 {
+		if firstErr == nil {
+			firstErr = errors.New("no data transferred")
+		}
+		return nil, elapsed, firstErr
+	}
+	if final.BytesTotal < tt.targetBytes && !tt.dataCap.Reached() {
+		err := firstErr
+		if err == nil {
+			err = ctx.Err()
+		}
+		return nil, elapsed, fmt.Errorf("transferred only %d of %d bytes: %w", final.BytesTotal, tt.targetBytes, err)
+	}
+	return &phaseResult{
+		bytes:   final.BytesTotal,
+		rate:    final.Rate,
+		streams: m.Streams(),
+		series:  m.Series(),
+	}, elapsed, nil
+}
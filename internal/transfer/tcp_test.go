@@ -0,0 +1,264 @@
+package transfer
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// tcpServer is a local stand-in for a speedtest.net TCP protocol server
+type tcpServer struct {
+	addr     string
+	uploaded atomic.Int64
+}
+
+func startTCPServer(t *testing.T) *tcpServer {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	s := &tcpServer{addr: ln.Addr().String()}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *tcpServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "HI":
+			io.WriteString(conn, "HELLO 2.9 (2.9.3) test\n")
+		case "PING":
+			fmt.Fprintf(conn, "PONG %d\n", time.Now().UnixMilli())
+		case "DOWNLOAD":
+			size, _ := strconv.ParseInt(fields[1], 10, 64)
+			body := "DOWNLOAD " + strings.Repeat("x", int(size)-len("DOWNLOAD ")-1) + "\n"
+			io.WriteString(conn, body)
+		case "UPLOAD":
+			size, _ := strconv.ParseInt(fields[1], 10, 64)
+			n, err := io.CopyN(io.Discard, r, size-int64(len(line)))
+			if err != nil {
+				return
+			}
+			s.uploaded.Add(n + int64(len(line)))
+			fmt.Fprintf(conn, "OK %d 1\n", size)
+		case "QUIT":
+			return
+		}
+	}
+}
+
+func TestDialTCP_Commands(t *testing.T) {
+	server := startTCPServer(t)
+
+	conn, err := DialTCP(context.Background(), nil, server.addr)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer conn.Close()
+
+	if !strings.HasPrefix(conn.Greeting, "HELLO") {
+		t.Errorf("Expected HELLO greeting, got: %q", conn.Greeting)
+	}
+
+	if latency, err := conn.Ping(); err != nil || latency <= 0 {
+		t.Errorf("Ping() = %v, %v", latency, err)
+	}
+
+	var reported int64
+	n, err := conn.Download(10000, func(n int64) { reported += n })
+	if err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	if n != 10000 || reported != 10000 {
+		t.Errorf("Expected 10000 bytes downloaded and reported, got %d and %d", n, reported)
+	}
+
+	// The connection stays usable after a download
+	if _, err := conn.Ping(); err != nil {
+		t.Errorf("Ping after download failed: %v", err)
+	}
+
+	reported = 0
+	n, err = conn.Upload(50000, []byte("0123456789"), func(n int64) { reported += n })
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if n != 50000 || reported != 50000 {
+		t.Errorf("Expected 50000 bytes uploaded and reported, got %d and %d", n, reported)
+	}
+	if got := server.uploaded.Load(); got != 50000 {
+		t.Errorf("Expected server to receive 50000 bytes, got %d", got)
+	}
+}
+
+func TestDialTCP_BadGreeting(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.WriteString(conn, "HTTP/1.1 400 Bad Request\r\n")
+	}()
+
+	if _, err := DialTCP(context.Background(), nil, ln.Addr().String()); err == nil {
+		t.Error("Expected error for a server that does not speak the TCP protocol")
+	}
+}
+
+func TestTCPTest_Measure(t *testing.T) {
+	server := startTCPServer(t)
+
+	tt := NewTCPTest(nil)
+	tt.testDuration = 200 * time.Millisecond
+	tt.chunkSize = 64 * 1024
+	ctx := context.Background()
+
+	latencies, err := tt.Ping(ctx, server.addr)
+	if err != nil {
+		t.Fatalf("Ping failed: %v", err)
+	}
+	if len(latencies) != tt.numPings {
+		t.Errorf("Expected %d latencies, got %d", tt.numPings, len(latencies))
+	}
+
+	download, err := tt.Download(ctx, server.addr)
+	if err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	if download.Bytes <= 0 || download.Bandwidth <= 0 || download.Protocol != "tcp" {
+		t.Errorf("Unexpected download result: %+v", download)
+	}
+
+	upload, err := tt.Upload(ctx, server.addr)
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if upload.Bytes <= 0 || upload.Bandwidth <= 0 || upload.Protocol != "tcp" {
+		t.Errorf("Unexpected upload result: %+v", upload)
+	}
+}
+
+func TestTCPTest_TargetBytes(t *testing.T) {
+	server := startTCPServer(t)
+
+	const target = 5*64*1024 + 1000
+	tt := NewTCPTest(nil)
+	tt.testDuration = time.Millisecond
+	tt.chunkSize = 64 * 1024
+	tt.SetTargetBytes(target)
+	ctx := context.Background()
+
+	download, err := tt.Download(ctx, server.addr)
+	if err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	if download.Bytes != target {
+		t.Errorf("Expected exactly %d bytes downloaded, got %d", target, download.Bytes)
+	}
+	if want := int64(float64(target) / download.Elapsed.Seconds()); download.Bandwidth != want {
+		t.Errorf("Expected the average bandwidth %d, got %d", want, download.Bandwidth)
+	}
+
+	upload, err := tt.Upload(ctx, server.addr)
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if upload.Bytes != target || server.uploaded.Load() != target {
+		t.Errorf("Expected exactly %d bytes uploaded, got result %d and server %d", target, upload.Bytes, server.uploaded.Load())
+	}
+	if want := int64(float64(target) / upload.Elapsed.Seconds()); upload.Bandwidth != want {
+		t.Errorf("Expected the average bandwidth %d, got %d", want, upload.Bandwidth)
+	}
+}
+
+func TestTCPTest_SmallRemainder(t *testing.T) {
+	// Each phase ends with a remainder too small for a transfer of its own
+	const target = 3*64*1024 + 5
+
+	tests := []struct {
+		name  string
+		setup func(tt *TCPTest) *DataCap
+	}{
+		{"target", func(tt *TCPTest) *DataCap {
+			tt.SetTargetBytes(target)
+			return nil
+		}},
+		{"data cap", func(tt *TCPTest) *DataCap {
+			limit := NewDataCap(target)
+			tt.SetDataCap(limit)
+			return limit
+		}},
+	}
+
+	for _, tc := range tests {
+		server := startTCPServer(t)
+		tt := NewTCPTest(nil)
+		tt.testDuration = 10 * time.Second
+		tt.chunkSize = 64 * 1024
+		limit := tc.setup(tt)
+
+		upload, err := tt.Upload(context.Background(), server.addr)
+		if err != nil {
+			t.Fatalf("%s: upload failed: %v", tc.name, err)
+		}
+		if upload.Bytes != target || server.uploaded.Load() != target {
+			t.Errorf("%s: expected exactly %d bytes uploaded, got result %d and server %d", tc.name, target, upload.Bytes, server.uploaded.Load())
+		}
+		if limit != nil && !limit.Reached() {
+			t.Errorf("%s: expected the data cap to be reached", tc.name)
+		}
+	}
+}
+
+func TestTCPTest_Unreachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	tt := NewTCPTest(nil)
+	tt.testDuration = 200 * time.Millisecond
+	if _, err := tt.Download(context.Background(), addr); err == nil {
+		t.Error("Expected error when no data could be transferred")
+	}
+}
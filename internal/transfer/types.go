@@ -1,9 +1,41 @@
 package transfer
 
+import "time"
+
 // ProgressInfo contains transfer progress information
 type ProgressInfo struct {
 	Rate         float64 // bytes per second
 	BytesTotal   int64
 	BytesCurrent int64
-	Progress     float64 // 0-1
+	Progress     float64       // 0-1
+	Elapsed      time.Duration // time since the phase started
+	ETA          time.Duration // estimated time until the phase ends
+	Protocol     string        // negotiated protocol, e.g. "HTTP/2.0"
+}
+
+// phaseProgress estimates how far a phase that ends after duration has got
+func phaseProgress(elapsed, duration time.Duration) (float64, time.Duration) {
+	if duration <= 0 || elapsed >= duration {
+		return 1, 0
+	}
+	return float64(elapsed) / float64(duration), duration - elapsed
+}
+
+// forwardProgress returns a channel whose updates are passed to fn, and a
+// function that waits until the channel has been closed and drained. Without
+// fn there is no channel and nothing to wait for.
+func forwardProgress(fn func(ProgressInfo)) (chan<- ProgressInfo, func()) {
+	if fn == nil {
+		return nil, func() {}
+	}
+
+	progress := make(chan ProgressInfo, 10)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for p := range progress {
+			fn(p)
+		}
+	}()
+	return progress, func() { <-done }
 }
@@ -7,10 +7,11 @@ import (
 	"io"
 	"math/rand"
 	"net/http"
-	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/user/speed-test-go/internal/network"
+	"github.com/user/speed-test-go/pkg/types"
 )
 
 // UploadTest performs upload speed testing
@@ -20,12 +21,17 @@ type UploadTest struct {
 	testDuration time.Duration
 	captureFreq  time.Duration
 	uploadSize   int64
+	fallbackURLs []string
+	samples      bool
+	targetBytes  int64
+	dataCap      *DataCap
+	onProgress   func(ProgressInfo)
 }
 
 // NewUploadTest creates a new upload test instance
 func NewUploadTest() *UploadTest {
 	return &UploadTest{
-		client:       network.UploadClient(),
+		client:       network.DefaultFactory().UploadClient(),
 		numThreads:   2,
 		testDuration: 20 * time.Second,
 		captureFreq:  100 * time.Millisecond,
@@ -33,25 +39,70 @@ func NewUploadTest() *UploadTest {
 	}
 }
 
+// DefaultUploadFallbackURLs are public echo endpoints used when the server's
+// own upload URLs fail and fallbacks are enabled with SetFallbackURLs
+var DefaultUploadFallbackURLs = []string{
+	// Asia Pacific echo servers (better latency from Indonesia)
+	"https://postman-echo.com/post",
+	"https://reqres.in/api/posts",
+	// US fallback
+	"https://httpbin.org/post",
+}
+
+// SetClient sets the HTTP client used for uploads
+func (ut *UploadTest) SetClient(client *http.Client) {
+	ut.client = client
+}
+
+// SetFallbackURLs sets the URLs a thread switches to once all of the server's
+// URLs have failed in a row. Fallbacks are disabled by default.
+func (ut *UploadTest) SetFallbackURLs(urls []string) {
+	ut.fallbackURLs = urls
+}
+
+// SetSamples enables recording a throughput time series at captureFreq
+func (ut *UploadTest) SetSamples(enabled bool) {
+	ut.samples = enabled
+}
+
+// SetTargetBytes makes the test upload exactly n bytes in requests of up to
+// the upload size, however long it takes, instead of one request per thread
+// within the test duration. 0 restores timed tests.
+func (ut *UploadTest) SetTargetBytes(n int64) {
+	ut.targetBytes = n
+}
+
+// SetDataCap stops the test once limit has been used up, e.g. shared with
+// the other phases of a run
+func (ut *UploadTest) SetDataCap(limit *DataCap) {
+	ut.dataCap = limit
+}
+
+// SetProgress makes Measure report progress to fn every captureFreq
+func (ut *UploadTest) SetProgress(fn func(ProgressInfo)) {
+	ut.onProgress = fn
+}
+
 // Run executes the upload test
 func (ut *UploadTest) Run(ctx context.Context, serverURL string, progress chan<- ProgressInfo) error {
-	rateCalc := NewRateCalculator()
-	rateCalc.Start()
+	_, err := ut.run(ctx, serverURL, progress)
+	return err
+}
 
-	var totalBytes int64
-	var mu sync.Mutex
-	var wg sync.WaitGroup
+func (ut *UploadTest) run(ctx context.Context, serverURL string, progress chan<- ProgressInfo) (*phaseResult, error) {
+	stats := newURLStats()
+	m := newMeter(ut.numThreads, ut.captureFreq, ut.testDuration)
+	if ut.samples {
+		m.RecordSeries()
+	}
+	budget := phaseBudget(ut.targetBytes, ut.dataCap)
+	if ut.targetBytes > 0 {
+		m.SetTarget(ut.targetBytes)
+	}
 
-	// Try speedtest.net URLs first, then public echo servers
-	// Prioritized by geographic proximity to Indonesia
 	uploadURLs := []string{
 		fmt.Sprintf("%s/speedtest/upload.php", serverURL),
 		fmt.Sprintf("%s/upload.php", serverURL),
-		// Asia Pacific echo servers (better latency from Indonesia)
-		"https://postman-echo.com/post",
-		"https://reqres.in/api/posts",
-		// US fallback
-		"https://httpbin.org/post",
 	}
 
 	// Generate random upload data
@@ -59,126 +110,146 @@ func (ut *UploadTest) Run(ctx context.Context, serverURL string, progress chan<-
 	rand.Read(uploadData)
 
 	// Create cancellation context with timeout
-	testCtx, cancel := context.WithTimeout(ctx, ut.testDuration)
+	testCtx, cancel := phaseContext(ctx, ut.testDuration, ut.targetBytes)
 	defer cancel()
 
-	// Run upload threads
-	for i := 0; i < ut.numThreads; i++ {
-		wg.Add(1)
-		go func(threadID int) {
-			defer wg.Done()
+	// Each thread uploads until its first successful request, or in
+	// fixed-size mode until the budget is spent
+	final := runPhase(ctx, m, ut.numThreads, progress, func(id int, stream *streamStats) {
+		urls := newURLSequence(uploadURLs, ut.fallbackURLs)
+		for testCtx.Err() == nil {
+			size := budget.Claim(ut.uploadSize)
+			if size == 0 {
+				return
+			}
 
-			for {
-				select {
-				case <-testCtx.Done():
-					return
-				default:
+			url := urls.URL()
+			stream.Request()
+			status, proto, sent, err := ut.post(testCtx, url, uploadData[:size])
+			if err != nil {
+				// The bytes sent before the failure count against the data
+				// cap, but a fixed-size phase has to send them again
+				budget.Release(size - sent)
+				if ut.targetBytes > 0 {
+					budget.Refill(sent)
 				}
-
-				success := false
-				for _, url := range uploadURLs {
-					req, err := http.NewRequestWithContext(testCtx, "POST", url, bytes.NewReader(uploadData))
-					if err != nil {
-						continue
-					}
-					req.Header.Set("Content-Type", "application/octet-stream")
-					req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
-
-					resp, err := ut.client.Do(req)
-					if err != nil {
-						continue
-					}
-
-					// Read response to complete the request
-					io.Copy(io.Discard, resp.Body)
-					resp.Body.Close()
-
-					if resp.StatusCode == http.StatusOK {
-						mu.Lock()
-						rateCalc.SetBytes(ut.uploadSize)
-						totalBytes += ut.uploadSize
-						mu.Unlock()
-
-						// Send progress update
-						if progress != nil {
-							select {
-							case progress <- ProgressInfo{
-								Rate:       rateCalc.Rate(),
-								BytesTotal: totalBytes,
-								Progress:   0.5,
-							}:
-							case <-testCtx.Done():
-							case <-ctx.Done():
-							}
-						}
-						success = true
-						break
-					}
+				// A request cut short by the end of the test is not a failure
+				if testCtx.Err() != nil {
+					return
 				}
+				stats.Record(url, status, 0, err)
+				urls.Done(false)
+				sleepContext(testCtx, retryDelay)
+				continue
+			}
 
-				if success {
-					break
-				}
+			stats.Record(url, status, size, nil)
+			stream.Add(size)
+			m.SetProtocol(proto)
+			urls.Done(true)
+			if ut.targetBytes == 0 {
+				return
 			}
-		}(i)
+		}
+	})
+
+	attempts := stats.Attempts()
+	if final.BytesTotal == 0 && ctx.Err() == nil {
+		return nil, fmt.Errorf("no data uploaded to %s: %s", serverURL, attempts.Summary())
+	}
+	if final.BytesTotal < ut.targetBytes && !ut.dataCap.Reached() {
+		return nil, fmt.Errorf("uploaded only %d of %d bytes to %s: %w", final.BytesTotal, ut.targetBytes, serverURL, ctx.Err())
+	}
+
+	return &phaseResult{
+		bytes:    final.BytesTotal,
+		rate:     final.Rate,
+		protocol: final.Protocol,
+		attempts: attempts,
+		streams:  m.Streams(),
+		series:   m.Series(),
+	}, nil
+}
+
+// post uploads data to url and returns the response status (0 when none
+// arrived), the negotiated protocol and the number of bytes of data sent,
+// which is less than len(data) if the request failed part way
+func (ut *UploadTest) post(ctx context.Context, url string, data []byte) (int, string, int64, error) {
+	body := &countingReader{r: bytes.NewReader(data)}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, body)
+	if err != nil {
+		return 0, "", 0, err
 	}
+	req.ContentLength = int64(len(data))
+	req.Header.Set("Content-Type", "application/octet-stream")
 
-	wg.Wait()
+	resp, err := ut.client.Do(req)
+	if err != nil {
+		return 0, "", body.n.Load(), err
+	}
+	defer resp.Body.Close()
 
-	// Send final progress
-	if progress != nil {
-		close(progress)
+	// Read response to complete the request
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		return resp.StatusCode, "", body.n.Load(), err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, "", body.n.Load(), &statusError{url: url, code: resp.StatusCode}
 	}
 
-	return nil
+	return resp.StatusCode, resp.Proto, body.n.Load(), nil
+}
+
+// countingReader counts the bytes read from r. The HTTP transport may still be
+// reading a request body when a failed request returns, so the count is atomic.
+type countingReader struct {
+	r io.Reader
+	n atomic.Int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n.Add(int64(n))
+	return n, err
 }
 
 // UploadResult contains the final upload test results
 type UploadResult struct {
-	Bandwidth      int64         // bytes per second
-	Bytes          int64         // total bytes transferred
-	Elapsed        time.Duration // total test duration
-	URLAttempts    int           // number of URLs tried
-	FailedAttempts int           // number of failed attempts
+	Bandwidth int64                  // bytes per second
+	Bytes     int64                  // total bytes transferred
+	Elapsed   time.Duration          // total test duration
+	Protocol  string                 // negotiated HTTP protocol
+	Attempts                         // requests, status codes and errors
+	Streams   []types.StreamStats    // per-thread throughput
+	Series    []types.TransferSample // throughput over time, if enabled
 }
 
 // RunSimpleUploadTest is a simplified upload test
 func RunSimpleUploadTest(ctx context.Context, serverURL string) (*UploadResult, error) {
-	ut := NewUploadTest()
-
-	result := &UploadResult{
-		Bandwidth: 0,
-		Bytes:     0,
-		Elapsed:   0,
-	}
+	return NewUploadTest().Measure(ctx, serverURL)
+}
 
+// Measure runs the upload test and collects the final result
+func (ut *UploadTest) Measure(ctx context.Context, serverURL string) (*UploadResult, error) {
+	progress, wait := forwardProgress(ut.onProgress)
 	start := time.Now()
+	phase, err := ut.run(ctx, serverURL, progress)
+	elapsed := time.Since(start)
+	wait()
+	if err != nil {
+		return nil, err
+	}
 
-	progress := make(chan ProgressInfo, 10)
-	errChan := make(chan error, 1)
-
-	go func() {
-		errChan <- ut.Run(ctx, serverURL, progress)
-	}()
-
-	// Wait for completion or timeout
-	select {
-	case err := <-errChan:
-		result.Elapsed = time.Since(start)
-		// Get last progress
-		for p := range progress {
-			result.Bytes = p.BytesTotal
-			result.Bandwidth = int64(p.Rate)
-		}
-		if err != nil {
-			return nil, err
-		}
-	case <-ctx.Done():
-		result.Elapsed = time.Since(start)
-		for p := range progress {
-			result.Bytes = p.BytesTotal
-			result.Bandwidth = int64(p.Rate)
-		}
+	result := &UploadResult{
+		Bandwidth: phaseBandwidth(phase, ut.targetBytes, elapsed),
+		Bytes:     phase.bytes,
+		Elapsed:   elapsed,
+		Protocol:  phase.protocol,
+		Attempts:  phase.attempts,
+		Streams:   phase.streams,
+		Series:    phase.series,
+	}
+	if ctx.Err() != nil {
 		return result, ctx.Err()
 	}
 
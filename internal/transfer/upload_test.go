@@ -5,7 +5,9 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -467,3 +469,107 @@ func TestUploadTest_RandomDataGeneration(t *testing.T) {
 		t.Error("Upload size not set correctly")
 	}
 }
+
+func TestUploadTest_Measure_NoData(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	ut := NewUploadTest()
+	ut.uploadSize = 1024
+	ut.testDuration = 300 * time.Millisecond
+
+	_, err := ut.Measure(context.Background(), server.URL)
+	if err == nil {
+		t.Fatal("Expected error when no bytes were uploaded")
+	}
+	if !strings.Contains(err.Error(), "no data uploaded") || !strings.Contains(err.Error(), "(status: ") {
+		t.Errorf("Expected descriptive error, got: %v", err)
+	}
+}
+
+func TestUploadTest_Measure_TargetBytes(t *testing.T) {
+	var received, requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := io.Copy(io.Discard, r.Body)
+		received.Add(n)
+		requests.Add(1)
+	}))
+	defer server.Close()
+
+	const target = 250_000
+	ut := NewUploadTest()
+	ut.uploadSize = 100_000
+	ut.testDuration = time.Millisecond
+	ut.SetTargetBytes(target)
+
+	result, err := ut.Measure(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Bytes != target || received.Load() != target {
+		t.Errorf("Expected exactly %d bytes, got result %d and server %d", target, result.Bytes, received.Load())
+	}
+	if want := int64(float64(target) / result.Elapsed.Seconds()); result.Bandwidth != want {
+		t.Errorf("Expected the average bandwidth %d, got %d", want, result.Bandwidth)
+	}
+	// Two full requests and one for the remainder
+	if requests.Load() != 3 {
+		t.Errorf("Expected 3 requests, got %d", requests.Load())
+	}
+}
+
+func TestUploadTest_Measure_DataCap(t *testing.T) {
+	var received atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := io.Copy(io.Discard, r.Body)
+		received.Add(n)
+	}))
+	defer server.Close()
+
+	// Two threads of 100000 bytes each under a cap of 150000
+	ut := NewUploadTest()
+	ut.uploadSize = 100_000
+	ut.SetDataCap(NewDataCap(150_000))
+
+	result, err := ut.Measure(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Bytes != 150_000 || received.Load() != 150_000 {
+		t.Errorf("Expected the cap to limit the upload to 150000 bytes, got result %d and server %d", result.Bytes, received.Load())
+	}
+}
+
+func TestUploadTest_Measure_FailedUploadUsesDataCap(t *testing.T) {
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		// The first upload is sent in full but rejected
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	const target = 100_000
+	limit := NewDataCap(1_000_000)
+	ut := NewUploadTest()
+	ut.numThreads = 1
+	ut.uploadSize = target
+	ut.SetTargetBytes(target)
+	ut.SetDataCap(limit)
+
+	result, err := ut.Measure(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Bytes != target {
+		t.Errorf("Expected the retry to upload all %d bytes, got %d", target, result.Bytes)
+	}
+	if used := limit.Used(); used != 2*target {
+		t.Errorf("Expected both uploads to count against the cap, got %d bytes", used)
+	}
+}
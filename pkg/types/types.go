@@ -7,26 +7,108 @@ import (
 
 // SpeedTestResult represents the final result of a speed test
 type SpeedTestResult struct {
-	Timestamp time.Time      `json:"timestamp"`
-	Ping      PingResult     `json:"ping"`
-	Download  TransferResult `json:"download"`
-	Upload    TransferResult `json:"upload"`
-	Server    *ServerInfo    `json:"server,omitempty"`
-	Interface *InterfaceInfo `json:"interface,omitempty"`
-	ISP       string         `json:"isp,omitempty"`
+	Timestamp     time.Time      `json:"timestamp"`
+	Ping          PingResult     `json:"ping"`
+	Download      TransferResult `json:"download"`
+	Upload        TransferResult `json:"upload"`
+	Server        *ServerInfo    `json:"server,omitempty"`
+	Interface     *InterfaceInfo `json:"interface,omitempty"`
+	ISP           string         `json:"isp,omitempty"`
+	Cache         *CacheInfo     `json:"cache,omitempty"`
+	Error         string         `json:"error,omitempty"`
+	AddressFamily string         `json:"addressFamily,omitempty"` // "ipv4" or "ipv6"
+	DNS           *DNSResult     `json:"dns,omitempty"`
+	Insecure      bool           `json:"insecure,omitempty"`  // TLS certificate verification was disabled
+	DataUsed      int64          `json:"dataUsed,omitempty"`  // bytes transferred by the download and upload
+	Truncated     bool           `json:"truncated,omitempty"` // transfers were stopped by the data cap
+}
+
+// DNSResult contains the resolution time of the server host
+type DNSResult struct {
+	Host      string   `json:"host"`
+	Server    string   `json:"server,omitempty"` // resolver address, empty for the system resolver
+	Duration  float64  `json:"duration"`         // milliseconds
+	Addresses []string `json:"addresses,omitempty"`
+}
+
+// CacheInfo describes cached discovery data used during a test
+type CacheInfo struct {
+	Location   *CacheEntry `json:"location,omitempty"`
+	ServerList *CacheEntry `json:"serverList,omitempty"`
+}
+
+// CacheEntry describes a single cached value that was used instead of a fetch
+type CacheEntry struct {
+	Age   int64 `json:"age"`   // seconds
+	Stale bool  `json:"stale"` // true when used because the fetch failed
+}
+
+// ComparisonResult contains the results of testing several servers in one run
+type ComparisonResult struct {
+	Timestamp time.Time          `json:"timestamp"`
+	Results   []*SpeedTestResult `json:"results"`
 }
 
 // PingResult contains ping/latency measurements
 type PingResult struct {
-	Jitter  float64 `json:"jitter"`  // milliseconds
+	Jitter  float64 `json:"jitter"`            // milliseconds
+	Latency float64 `json:"latency"`           // milliseconds
+	Method  string  `json:"method,omitempty"`  // "websocket" or "http"
+	Samples int     `json:"samples,omitempty"` // number of round trips measured
+
+	Series []LatencySample `json:"series,omitempty"` // every probe, with --samples
+}
+
+// LatencySample is a single latency probe of a time series
+type LatencySample struct {
+	Elapsed int64   `json:"elapsed"` // milliseconds since the phase started
 	Latency float64 `json:"latency"` // milliseconds
 }
 
 // TransferResult contains download/upload measurements
 type TransferResult struct {
-	Bandwidth int64 `json:"bandwidth"` // bytes per second
-	Bytes     int64 `json:"bytes"`
-	Elapsed   int64 `json:"elapsed"` // milliseconds
+	Bandwidth int64      `json:"bandwidth"` // bytes per second
+	Bytes     int64      `json:"bytes"`
+	Elapsed   int64      `json:"elapsed"`            // milliseconds
+	Protocol  string     `json:"protocol,omitempty"` // negotiated HTTP protocol, e.g. "HTTP/2.0"
+	URLs      []URLStats `json:"urls,omitempty"`     // endpoints that were requested
+
+	// Byte budget of a fixed-size transfer; Elapsed is the time it took
+	TargetBytes int64 `json:"targetBytes,omitempty"`
+
+	// Requests made during the phase
+	Attempts       int            `json:"attempts,omitempty"`
+	FailedAttempts int            `json:"failedAttempts,omitempty"`
+	StatusCodes    map[int]int    `json:"statusCodes,omitempty"` // responses by HTTP status
+	Errors         map[string]int `json:"errors,omitempty"`      // failures by category: dns, connect, timeout, reset, status, other
+
+	Streams []StreamStats    `json:"streams,omitempty"` // per-connection throughput
+	Series  []TransferSample `json:"series,omitempty"`  // throughput over time, with --samples
+}
+
+// TransferSample is a single point of a throughput time series
+type TransferSample struct {
+	Elapsed int64   `json:"elapsed"` // milliseconds since the phase started
+	Bytes   int64   `json:"bytes"`   // total bytes transferred so far
+	Rate    float64 `json:"rate"`    // bytes per second since the previous sample
+}
+
+// StreamStats describes the throughput of one parallel transfer stream
+type StreamStats struct {
+	ID            int   `json:"id"`
+	Bytes         int64 `json:"bytes"`
+	Duration      int64 `json:"duration"`      // milliseconds from start to last byte
+	Bandwidth     int64 `json:"bandwidth"`     // average bytes per second
+	PeakBandwidth int64 `json:"peakBandwidth"` // highest bytes per second over a short window
+	Requests      int   `json:"requests"`
+}
+
+// URLStats describes what a single endpoint contributed to a transfer phase
+type URLStats struct {
+	URL       string `json:"url"`
+	Bytes     int64  `json:"bytes"`
+	Successes int    `json:"successes"`
+	Failures  int    `json:"failures"`
 }
 
 // ServerInfo contains information about the test server